@@ -7,7 +7,6 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
 var ErrNotFound = fmt.Errorf("record not found")
@@ -35,6 +34,10 @@ func (table TableAction[R]) Reconstitute(recordWithKey *R) (err error) {
 	if primaryKey.SortKey != nil {
 		keys[primaryKey.SortKey.Name] = primaryKey.SortKey.AttributeValue()
 	}
+	err = table.encode(keys)
+	if err != nil {
+		return
+	}
 	result, err := table.DynamodbClient.GetItem(&dynamodb.GetItemInput{
 		TableName: aws.String(table.Table.Name),
 		Key:       keys,
@@ -46,7 +49,7 @@ func (table TableAction[R]) Reconstitute(recordWithKey *R) (err error) {
 		return ErrNotFound
 	}
 
-	err = dynamodbattribute.UnmarshalMap(result.Item, recordWithKey)
+	err = table.unmarshal(result.Item, recordWithKey)
 	if err != nil {
 		return
 	}
@@ -55,7 +58,7 @@ func (table TableAction[R]) Reconstitute(recordWithKey *R) (err error) {
 
 func (table TableAction[R]) Persist(record R) (err error) {
 
-	items, err := dynamodbattribute.MarshalMap(record)
+	items, err := table.marshal(record)
 	if err != nil {
 		return
 	}
@@ -69,17 +72,25 @@ func (table TableAction[R]) Persist(record R) (err error) {
 
 func (table TableAction[R]) Query(partitionKey DynamodbKey, cursor *string, limit int) (records []R, nextCursor *string, err error) {
 
+	keys := map[string]*dynamodb.AttributeValue{
+		partitionKey.Name: partitionKey.AttributeValue(),
+	}
+	err = table.encode(keys)
+	if err != nil {
+		return
+	}
+
 	queryInput := &dynamodb.QueryInput{
 		TableName:              aws.String(table.Name),
 		KeyConditionExpression: aws.String(fmt.Sprintf("%s = :the_partition_key", partitionKey.Name)),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":the_partition_key": partitionKey.AttributeValue(),
+			":the_partition_key": keys[partitionKey.Name],
 		},
 		ScanIndexForward: aws.Bool(false),
 	}
 
 	if cursor != nil {
-		exclusiveStartKey, errOfDecoding := decodeCursor(*cursor)
+		exclusiveStartKey, errOfDecoding := DecodeCursor(*cursor)
 		if errOfDecoding != nil {
 			err = errOfDecoding
 			return
@@ -94,20 +105,19 @@ func (table TableAction[R]) Query(partitionKey DynamodbKey, cursor *string, limi
 		return
 	}
 
-	records = make([]R, len(items.Items))
-	err = dynamodbattribute.UnmarshalListOfMaps(items.Items, &records)
+	records, err = table.unmarshalList(items.Items)
 	if err != nil {
 		return
 	}
 
-	nextCursor, err = encodeCursor(items.LastEvaluatedKey)
+	nextCursor, err = EncodeCursor(items.LastEvaluatedKey)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func decodeCursor(cursor string) (exclusiveStartKey map[string]*dynamodb.AttributeValue, err error) {
+func DecodeCursor(cursor string) (exclusiveStartKey map[string]*dynamodb.AttributeValue, err error) {
 	var decodedCursor []byte
 	decodedCursor, err = base64.StdEncoding.DecodeString(cursor)
 	if err != nil {
@@ -126,7 +136,7 @@ func decodeCursor(cursor string) (exclusiveStartKey map[string]*dynamodb.Attribu
 	return
 }
 
-func encodeCursor(exclusiveStartKey map[string]*dynamodb.AttributeValue) (cursor *string, err error) {
+func EncodeCursor(exclusiveStartKey map[string]*dynamodb.AttributeValue) (cursor *string, err error) {
 	if len(exclusiveStartKey) == 0 {
 		return
 	}
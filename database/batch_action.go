@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/gitlotto/common/batcher"
+)
+
+const batchWriteSize = 25
+const batchWriteAttempts = 8
+const batchWriteBackoff = time.Millisecond * 50
+
+var ErrUnprocessedItems = fmt.Errorf("some items were not processed by the batch write")
+
+func (table TableAction[R]) BatchPersist(records []R) (err error) {
+	items := make([]map[string]*dynamodb.AttributeValue, len(records))
+	for i, record := range records {
+		items[i], err = table.marshal(record)
+		if err != nil {
+			return
+		}
+	}
+	err = table.batchWriteItems(items)
+	return
+}
+
+func (table TableAction[R]) batchWriteItems(items []map[string]*dynamodb.AttributeValue) (err error) {
+	for _, batch := range batcher.Batcher(items, batchWriteSize) {
+		writeRequests := make([]*dynamodb.WriteRequest, len(batch))
+		for i, item := range batch {
+			writeRequests[i] = &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{
+					Item: item,
+				},
+			}
+		}
+
+		requestItems := map[string][]*dynamodb.WriteRequest{
+			table.Name: writeRequests,
+		}
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt == batchWriteAttempts {
+				return ErrUnprocessedItems
+			}
+			if attempt > 0 {
+				time.Sleep(batchWriteBackoff * time.Duration(1<<(attempt-1)))
+			}
+
+			var output *dynamodb.BatchWriteItemOutput
+			output, err = table.DynamodbClient.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return
+			}
+			requestItems = output.UnprocessedItems
+		}
+	}
+	return
+}
+
+func (table TableAction[R]) scanPage(exclusiveStartKey map[string]*dynamodb.AttributeValue, limit int) (output *dynamodb.ScanOutput, err error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:         aws.String(table.Name),
+		ExclusiveStartKey: exclusiveStartKey,
+	}
+	if limit > 0 {
+		scanInput.Limit = aws.Int64(int64(limit))
+	}
+	output, err = table.DynamodbClient.Scan(scanInput)
+	return
+}
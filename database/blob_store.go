@@ -0,0 +1,115 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var ErrBlobNotFound = fmt.Errorf("blob not found")
+
+type BlobStore interface {
+	Upload(key string, blob []byte) (err error)
+	Download(key string) (blob []byte, err error)
+}
+
+type InMemoryBlobStore struct {
+	mutex *sync.RWMutex
+	blobs map[string][]byte
+}
+
+func NewInMemoryBlobStore() *InMemoryBlobStore {
+	return &InMemoryBlobStore{
+		mutex: &sync.RWMutex{},
+		blobs: map[string][]byte{},
+	}
+}
+
+func (store *InMemoryBlobStore) Upload(key string, blob []byte) (err error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.blobs[key] = bytes.Clone(blob)
+	return
+}
+
+func (store *InMemoryBlobStore) Download(key string) (blob []byte, err error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	storedBlob, ok := store.blobs[key]
+	if !ok {
+		err = ErrBlobNotFound
+		return
+	}
+	blob = bytes.Clone(storedBlob)
+	return
+}
+
+type FileSystemBlobStore struct {
+	Directory string
+}
+
+func (store FileSystemBlobStore) Upload(key string, blob []byte) (err error) {
+	path := filepath.Join(store.Directory, filepath.FromSlash(key))
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(path, blob, 0o644)
+	return
+}
+
+func (store FileSystemBlobStore) Download(key string) (blob []byte, err error) {
+	path := filepath.Join(store.Directory, filepath.FromSlash(key))
+	blob, err = os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = ErrBlobNotFound
+	}
+	return
+}
+
+type S3BlobStore struct {
+	s3Client *s3.S3
+	bucket   string
+}
+
+func NewS3BlobStore(session *session.Session, bucket string) *S3BlobStore {
+	return &S3BlobStore{
+		s3Client: s3.New(session),
+		bucket:   bucket,
+	}
+}
+
+func (store *S3BlobStore) Upload(key string, blob []byte) (err error) {
+	_, err = store.s3Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(blob),
+	})
+	return
+}
+
+func (store *S3BlobStore) Download(key string) (blob []byte, err error) {
+	output, err := store.s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if errRefined, ok := err.(awserr.Error); ok && errRefined.Code() == s3.ErrCodeNoSuchKey {
+		err = ErrBlobNotFound
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer output.Body.Close()
+	blob, err = io.ReadAll(output.Body)
+	return
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ItemCodec rewrites the attributes of an item on its way to and from DynamoDB.
+// Codecs of a table are applied in order on Encode and in reverse order on Decode.
+type ItemCodec interface {
+	Encode(item map[string]*dynamodb.AttributeValue) (err error)
+	Decode(item map[string]*dynamodb.AttributeValue) (err error)
+}
+
+func (table Table[R]) marshal(record R) (item map[string]*dynamodb.AttributeValue, err error) {
+	item, err = dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return
+	}
+	err = table.encode(item)
+	return
+}
+
+func (table Table[R]) unmarshal(item map[string]*dynamodb.AttributeValue, record *R) (err error) {
+	err = table.decode(item)
+	if err != nil {
+		return
+	}
+	err = dynamodbattribute.UnmarshalMap(item, record)
+	return
+}
+
+func (table Table[R]) unmarshalList(items []map[string]*dynamodb.AttributeValue) (records []R, err error) {
+	records = make([]R, len(items))
+	for i, item := range items {
+		err = table.unmarshal(item, &records[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (table Table[R]) encode(item map[string]*dynamodb.AttributeValue) (err error) {
+	for _, codec := range table.Codecs {
+		err = codec.Encode(item)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (table Table[R]) decode(item map[string]*dynamodb.AttributeValue) (err error) {
+	for i := len(table.Codecs) - 1; i >= 0; i-- {
+		err = table.Codecs[i].Decode(item)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/klauspost/compress/zstd"
+)
+
+type CompressionAlgorithm string
+
+const (
+	CompressionGzip CompressionAlgorithm = "gzip"
+	CompressionZstd CompressionAlgorithm = "zstd"
+)
+
+// the header byte tells the algorithm and the type of the original attribute
+const (
+	compressedStringWithGzip byte = 0xC1
+	compressedBinaryWithGzip byte = 0xC2
+	compressedStringWithZstd byte = 0xD1
+	compressedBinaryWithZstd byte = 0xD2
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// the zstd encoder and decoder are safe for concurrent EncodeAll and DecodeAll, so they are created once
+var zstdCodec = struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}{}
+
+func zstdEncoderAndDecoder() (encoder *zstd.Encoder, decoder *zstd.Decoder, err error) {
+	zstdCodec.once.Do(func() {
+		zstdCodec.encoder, zstdCodec.err = zstd.NewWriter(nil)
+		if zstdCodec.err != nil {
+			return
+		}
+		zstdCodec.decoder, zstdCodec.err = zstd.NewReader(nil)
+	})
+	return zstdCodec.encoder, zstdCodec.decoder, zstdCodec.err
+}
+
+func ErrUnsupportedCompressionAlgorithm(algorithm CompressionAlgorithm) error {
+	return fmt.Errorf("unsupported compression algorithm %s", algorithm)
+}
+
+func ErrUncompressibleAttribute(attributeName string) error {
+	return fmt.Errorf("only string and binary attributes can be compressed, %s is neither", attributeName)
+}
+
+// Compression stores the given string and binary attributes as compressed binary attributes.
+// Attributes written before the compression was switched on are read as they are
+// and attributes that are compressed already are not compressed again.
+type Compression struct {
+	Attributes []string
+	Algorithm  CompressionAlgorithm
+}
+
+func (compression Compression) Encode(item map[string]*dynamodb.AttributeValue) (err error) {
+	for _, attributeName := range compression.Attributes {
+		attribute, ok := item[attributeName]
+		if !ok || attribute == nil || isCompressedAttribute(attribute) {
+			continue
+		}
+
+		var content []byte
+		var isString bool
+		switch {
+		case attribute.S != nil:
+			content = []byte(*attribute.S)
+			isString = true
+		case attribute.B != nil:
+			content = attribute.B
+		default:
+			err = ErrUncompressibleAttribute(attributeName)
+			return
+		}
+
+		var header byte
+		var compressed []byte
+		switch compression.Algorithm {
+		case CompressionGzip:
+			header = compressedBinaryWithGzip
+			if isString {
+				header = compressedStringWithGzip
+			}
+			compressed, err = gzipCompress(content)
+		case CompressionZstd:
+			header = compressedBinaryWithZstd
+			if isString {
+				header = compressedStringWithZstd
+			}
+			var encoder *zstd.Encoder
+			encoder, _, err = zstdEncoderAndDecoder()
+			if err == nil {
+				compressed = encoder.EncodeAll(content, nil)
+			}
+		default:
+			err = ErrUnsupportedCompressionAlgorithm(compression.Algorithm)
+		}
+		if err != nil {
+			return
+		}
+
+		item[attributeName] = &dynamodb.AttributeValue{
+			B: append([]byte{header}, compressed...),
+		}
+	}
+	return
+}
+
+func (compression Compression) Decode(item map[string]*dynamodb.AttributeValue) (err error) {
+	for _, attributeName := range compression.Attributes {
+		attribute, ok := item[attributeName]
+		if !ok || attribute == nil || !isCompressedAttribute(attribute) {
+			continue
+		}
+
+		header := attribute.B[0]
+		var content []byte
+		switch header {
+		case compressedStringWithGzip, compressedBinaryWithGzip:
+			content, err = gzipDecompress(attribute.B[1:])
+		case compressedStringWithZstd, compressedBinaryWithZstd:
+			var decoder *zstd.Decoder
+			_, decoder, err = zstdEncoderAndDecoder()
+			if err == nil {
+				content, err = decoder.DecodeAll(attribute.B[1:], nil)
+			}
+		}
+		if err != nil {
+			return
+		}
+
+		switch header {
+		case compressedStringWithGzip, compressedStringWithZstd:
+			item[attributeName] = &dynamodb.AttributeValue{S: aws.String(string(content))}
+		default:
+			item[attributeName] = &dynamodb.AttributeValue{B: content}
+		}
+	}
+	return
+}
+
+func isCompressedAttribute(attribute *dynamodb.AttributeValue) bool {
+	if len(attribute.B) == 0 {
+		return false
+	}
+	payload := attribute.B[1:]
+	switch attribute.B[0] {
+	case compressedStringWithGzip, compressedBinaryWithGzip:
+		return bytes.HasPrefix(payload, gzipMagic)
+	case compressedStringWithZstd, compressedBinaryWithZstd:
+		return bytes.HasPrefix(payload, zstdMagic)
+	}
+	return false
+}
+
+func gzipCompress(content []byte) (compressed []byte, err error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err = writer.Write(content)
+	if err != nil {
+		return
+	}
+	err = writer.Close()
+	if err != nil {
+		return
+	}
+	compressed = buffer.Bytes()
+	return
+}
+
+func gzipDecompress(compressed []byte) (content []byte, err error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	content, err = io.ReadAll(reader)
+	return
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Compression_should_compress_string_and_binary_attributes_and_decompress_them_back(t *testing.T) {
+	for _, algorithm := range []CompressionAlgorithm{CompressionGzip, CompressionZstd} {
+		compression := Compression{
+			Attributes: []string{"some_json", "some_binary"},
+			Algorithm:  algorithm,
+		}
+
+		bigJson := `{"values":[` + strings.Repeat(`"value",`, 200) + `"value"]}`
+		binary := []byte(strings.Repeat("binary", 100))
+		item := map[string]*dynamodb.AttributeValue{
+			"some_json": {
+				S: aws.String(bigJson),
+			},
+			"some_binary": {
+				B: binary,
+			},
+		}
+
+		err := compression.Encode(item)
+		assert.NoError(t, err)
+		assert.Nil(t, item["some_json"].S)
+		assert.Less(t, len(item["some_json"].B), len(bigJson))
+		assert.Less(t, len(item["some_binary"].B), len(binary))
+
+		err = compression.Decode(item)
+		assert.NoError(t, err)
+
+		expectedItem := map[string]*dynamodb.AttributeValue{
+			"some_json": {
+				S: aws.String(bigJson),
+			},
+			"some_binary": {
+				B: binary,
+			},
+		}
+		assert.Equal(t, expectedItem, item, string(algorithm))
+	}
+}
+
+func Test_Compression_should_not_compress_attributes_twice(t *testing.T) {
+	for _, algorithm := range []CompressionAlgorithm{CompressionGzip, CompressionZstd} {
+		compression := Compression{
+			Attributes: []string{"some_json"},
+			Algorithm:  algorithm,
+		}
+
+		json := `{"values":[` + strings.Repeat(`"value",`, 200) + `"value"]}`
+		item := map[string]*dynamodb.AttributeValue{
+			"some_json": {
+				S: aws.String(json),
+			},
+		}
+
+		err := compression.Encode(item)
+		assert.NoError(t, err)
+		compressed := item["some_json"].B
+
+		err = compression.Encode(item)
+		assert.NoError(t, err)
+		assert.Equal(t, compressed, item["some_json"].B, string(algorithm))
+
+		err = compression.Decode(item)
+		assert.NoError(t, err)
+		assert.Equal(t, aws.String(json), item["some_json"].S, string(algorithm))
+	}
+}
+
+func Test_Compression_should_read_uncompressed_attributes_stored_before_compression(t *testing.T) {
+	compression := Compression{
+		Attributes: []string{"some_json", "some_binary"},
+		Algorithm:  CompressionZstd,
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"some_json": {
+			S: aws.String(`{"legacy":true}`),
+		},
+		"some_binary": {
+			B: []byte{compressedBinaryWithZstd, 1, 2, 3},
+		},
+	}
+
+	err := compression.Decode(item)
+	assert.NoError(t, err)
+
+	expectedItem := map[string]*dynamodb.AttributeValue{
+		"some_json": {
+			S: aws.String(`{"legacy":true}`),
+		},
+		"some_binary": {
+			B: []byte{compressedBinaryWithZstd, 1, 2, 3},
+		},
+	}
+	assert.Equal(t, expectedItem, item)
+}
+
+func Test_Compression_should_reject_attributes_which_are_neither_string_nor_binary(t *testing.T) {
+	compression := Compression{
+		Attributes: []string{"some_number"},
+		Algorithm:  CompressionGzip,
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"some_number": {
+			N: aws.String("42"),
+		},
+	}
+
+	err := compression.Encode(item)
+	assert.Equal(t, ErrUncompressibleAttribute("some_number"), err)
+}
+
+func Test_SimpleRecord_with_compressed_attribute_should_be_persisted_and_reconstituted(t *testing.T) {
+	var err error
+
+	compressedSimpleRecordsTable := Table[simpleRecord]{
+		Name: simpleRecordsTableName,
+		Codecs: []ItemCodec{
+			Compression{
+				Attributes: []string{"some_value"},
+				Algorithm:  CompressionZstd,
+			},
+		},
+	}
+
+	partitionKey := uuid.New().String()
+	record := simpleRecord{
+		PartitionKey: partitionKey,
+		SomeValue:    strings.Repeat(`{"some":"json"}`, 100),
+	}
+
+	err = compressedSimpleRecordsTable.Action(dynamodbClient).Persist(record)
+	assert.NoError(t, err)
+
+	getEventOutput, err := dynamodbClient.GetItem(
+		&dynamodb.GetItemInput{
+			TableName: aws.String(simpleRecordsTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"partition_key": {
+					S: aws.String(partitionKey),
+				},
+			},
+		},
+	)
+	assert.NoError(t, err)
+	assert.True(t, isCompressedAttribute(getEventOutput.Item["some_value"]))
+
+	actualRecord := simpleRecord{
+		PartitionKey: partitionKey,
+	}
+	err = compressedSimpleRecordsTable.Action(dynamodbClient).Reconstitute(&actualRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, actualRecord)
+}
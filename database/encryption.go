@@ -0,0 +1,208 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const encryptedAttributePrefix = "enc:v1:"
+
+const (
+	encryptionModeRandomized    byte = 'R'
+	encryptionModeDeterministic byte = 'D'
+)
+
+var ErrInvalidEncryptedAttribute = fmt.Errorf("invalid encrypted attribute")
+var ErrDeterministicDataKeyMissing = fmt.Errorf("deterministic data key is missing")
+
+// Encryption encrypts the given attributes with a data key issued by the key provider (envelope encryption).
+// Every item gets its own data key unless the attribute is deterministic: deterministic attributes
+// are encrypted with DeterministicDataKey (an encrypted data key) so equal values give equal ciphertexts
+// and can be used as keys. Encrypted values are stored as strings, so only string keys can be encrypted.
+type Encryption struct {
+	Attributes              []string
+	DeterministicAttributes []string
+	KeyProvider             KeyProvider
+	DeterministicDataKey    []byte
+}
+
+func (encryption Encryption) Encode(item map[string]*dynamodb.AttributeValue) (err error) {
+	var plaintextKey, encryptedKey []byte
+	for _, attributeName := range encryption.Attributes {
+		attribute, ok := item[attributeName]
+		if !ok || attribute == nil || isEncryptedAttribute(attribute) {
+			continue
+		}
+		if plaintextKey == nil {
+			plaintextKey, encryptedKey, err = encryption.KeyProvider.GenerateDataKey()
+			if err != nil {
+				return
+			}
+		}
+		item[attributeName], err = encryptAttribute(attributeName, attribute, encryptionModeRandomized, plaintextKey, encryptedKey)
+		if err != nil {
+			return
+		}
+	}
+
+	var deterministicKey []byte
+	for _, attributeName := range encryption.DeterministicAttributes {
+		attribute, ok := item[attributeName]
+		if !ok || attribute == nil || isEncryptedAttribute(attribute) {
+			continue
+		}
+		if deterministicKey == nil {
+			if len(encryption.DeterministicDataKey) == 0 {
+				err = ErrDeterministicDataKeyMissing
+				return
+			}
+			deterministicKey, err = encryption.KeyProvider.DecryptDataKey(encryption.DeterministicDataKey)
+			if err != nil {
+				return
+			}
+		}
+		item[attributeName], err = encryptAttribute(attributeName, attribute, encryptionModeDeterministic, deterministicKey, encryption.DeterministicDataKey)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (encryption Encryption) Decode(item map[string]*dynamodb.AttributeValue) (err error) {
+	plaintextKeys := map[string][]byte{}
+	attributeNames := append(append([]string{}, encryption.Attributes...), encryption.DeterministicAttributes...)
+	for _, attributeName := range attributeNames {
+		attribute, ok := item[attributeName]
+		if !ok || !isEncryptedAttribute(attribute) {
+			continue
+		}
+
+		var payload []byte
+		payload, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(*attribute.S, encryptedAttributePrefix))
+		if err != nil {
+			return
+		}
+
+		var encryptedKey, nonceAndCiphertext []byte
+		encryptedKey, nonceAndCiphertext, err = splitEncryptedPayload(payload)
+		if err != nil {
+			return
+		}
+
+		plaintextKey, known := plaintextKeys[string(encryptedKey)]
+		if !known {
+			plaintextKey, err = encryption.KeyProvider.DecryptDataKey(encryptedKey)
+			if err != nil {
+				return
+			}
+			plaintextKeys[string(encryptedKey)] = plaintextKey
+		}
+
+		item[attributeName], err = decryptAttribute(attributeName, nonceAndCiphertext, plaintextKey)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func isEncryptedAttribute(attribute *dynamodb.AttributeValue) bool {
+	return attribute != nil && attribute.S != nil && strings.HasPrefix(*attribute.S, encryptedAttributePrefix)
+}
+
+func encryptAttribute(
+	attributeName string,
+	attribute *dynamodb.AttributeValue,
+	mode byte,
+	plaintextKey []byte,
+	encryptedKey []byte,
+) (encrypted *dynamodb.AttributeValue, err error) {
+	plaintext, err := json.Marshal(toJson(attribute))
+	if err != nil {
+		return
+	}
+
+	gcm, err := newGcm(plaintextKey)
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	switch mode {
+	case encryptionModeDeterministic:
+		mac := hmac.New(sha256.New, plaintextKey)
+		mac.Write([]byte(attributeName))
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	default:
+		_, err = rand.Read(nonce)
+		if err != nil {
+			return
+		}
+	}
+
+	payload := []byte{mode}
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(encryptedKey)))
+	payload = append(payload, encryptedKey...)
+	payload = append(payload, nonce...)
+	payload = gcm.Seal(payload, nonce, plaintext, []byte(attributeName))
+
+	encrypted = &dynamodb.AttributeValue{
+		S: aws.String(encryptedAttributePrefix + base64.StdEncoding.EncodeToString(payload)),
+	}
+	return
+}
+
+func splitEncryptedPayload(payload []byte) (encryptedKey []byte, nonceAndCiphertext []byte, err error) {
+	if len(payload) < 3 {
+		err = ErrInvalidEncryptedAttribute
+		return
+	}
+	mode := payload[0]
+	if mode != encryptionModeRandomized && mode != encryptionModeDeterministic {
+		err = ErrInvalidEncryptedAttribute
+		return
+	}
+	encryptedKeyLength := int(binary.BigEndian.Uint16(payload[1:3]))
+	if len(payload) < 3+encryptedKeyLength {
+		err = ErrInvalidEncryptedAttribute
+		return
+	}
+	encryptedKey = payload[3 : 3+encryptedKeyLength]
+	nonceAndCiphertext = payload[3+encryptedKeyLength:]
+	return
+}
+
+func decryptAttribute(attributeName string, nonceAndCiphertext []byte, plaintextKey []byte) (decrypted *dynamodb.AttributeValue, err error) {
+	gcm, err := newGcm(plaintextKey)
+	if err != nil {
+		return
+	}
+	nonceSize := gcm.NonceSize()
+	if len(nonceAndCiphertext) < nonceSize {
+		err = ErrInvalidEncryptedAttribute
+		return
+	}
+	plaintext, err := gcm.Open(nil, nonceAndCiphertext[:nonceSize], nonceAndCiphertext[nonceSize:], []byte(attributeName))
+	if err != nil {
+		return
+	}
+
+	var attributeInJson AttributeValueJSON
+	err = json.Unmarshal(plaintext, &attributeInJson)
+	if err != nil {
+		return
+	}
+	decrypted = fromJson(&attributeInJson)
+	return
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var localKeyProvider = LocalKeyProvider{
+	MasterKey: []byte("0123456789abcdef0123456789abcdef"),
+}
+
+func mustGenerateEncryptedDataKey() []byte {
+	_, encryptedKey, err := localKeyProvider.GenerateDataKey()
+	if err != nil {
+		panic(err)
+	}
+	return encryptedKey
+}
+
+func Test_Encryption_should_encrypt_attributes_and_decrypt_them_back(t *testing.T) {
+	var err error
+
+	encryption := Encryption{
+		Attributes:  []string{"some_value", "chat_id"},
+		KeyProvider: localKeyProvider,
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"partition_key": {
+			S: aws.String("partition key"),
+		},
+		"some_value": {
+			S: aws.String("user identifying data"),
+		},
+		"chat_id": {
+			N: aws.String("123456789"),
+		},
+	}
+
+	err = encryption.Encode(item)
+	assert.NoError(t, err)
+	assert.Equal(t, "partition key", *item["partition_key"].S)
+	assert.True(t, isEncryptedAttribute(item["some_value"]))
+	assert.True(t, isEncryptedAttribute(item["chat_id"]))
+	assert.NotContains(t, *item["some_value"].S, "user identifying data")
+
+	err = encryption.Decode(item)
+	assert.NoError(t, err)
+
+	expectedItem := map[string]*dynamodb.AttributeValue{
+		"partition_key": {
+			S: aws.String("partition key"),
+		},
+		"some_value": {
+			S: aws.String("user identifying data"),
+		},
+		"chat_id": {
+			N: aws.String("123456789"),
+		},
+	}
+	assert.Equal(t, expectedItem, item)
+}
+
+func Test_Encryption_should_produce_different_ciphertexts_for_randomized_attributes(t *testing.T) {
+	encryption := Encryption{
+		Attributes:  []string{"some_value"},
+		KeyProvider: localKeyProvider,
+	}
+
+	firstItem := map[string]*dynamodb.AttributeValue{"some_value": {S: aws.String("same value")}}
+	secondItem := map[string]*dynamodb.AttributeValue{"some_value": {S: aws.String("same value")}}
+
+	assert.NoError(t, encryption.Encode(firstItem))
+	assert.NoError(t, encryption.Encode(secondItem))
+	assert.NotEqual(t, *firstItem["some_value"].S, *secondItem["some_value"].S)
+}
+
+func Test_Encryption_should_produce_equal_ciphertexts_for_deterministic_attributes(t *testing.T) {
+	encryption := Encryption{
+		DeterministicAttributes: []string{"partition_key"},
+		KeyProvider:             localKeyProvider,
+		DeterministicDataKey:    mustGenerateEncryptedDataKey(),
+	}
+
+	firstItem := map[string]*dynamodb.AttributeValue{"partition_key": {S: aws.String("same value")}}
+	secondItem := map[string]*dynamodb.AttributeValue{"partition_key": {S: aws.String("same value")}}
+	otherItem := map[string]*dynamodb.AttributeValue{"partition_key": {S: aws.String("other value")}}
+
+	assert.NoError(t, encryption.Encode(firstItem))
+	assert.NoError(t, encryption.Encode(secondItem))
+	assert.NoError(t, encryption.Encode(otherItem))
+	assert.Equal(t, *firstItem["partition_key"].S, *secondItem["partition_key"].S)
+	assert.NotEqual(t, *firstItem["partition_key"].S, *otherItem["partition_key"].S)
+
+	assert.NoError(t, encryption.Decode(firstItem))
+	assert.Equal(t, "same value", *firstItem["partition_key"].S)
+}
+
+func Test_Encryption_should_read_plaintext_attributes_stored_before_encryption(t *testing.T) {
+	encryption := Encryption{
+		Attributes:  []string{"some_value"},
+		KeyProvider: localKeyProvider,
+	}
+
+	item := map[string]*dynamodb.AttributeValue{"some_value": {S: aws.String("legacy value")}}
+
+	assert.NoError(t, encryption.Decode(item))
+	assert.Equal(t, "legacy value", *item["some_value"].S)
+}
+
+func Test_Encryption_should_not_decrypt_with_another_master_key(t *testing.T) {
+	encryption := Encryption{
+		Attributes:  []string{"some_value"},
+		KeyProvider: localKeyProvider,
+	}
+
+	item := map[string]*dynamodb.AttributeValue{"some_value": {S: aws.String("secret")}}
+	assert.NoError(t, encryption.Encode(item))
+
+	encryption.KeyProvider = LocalKeyProvider{MasterKey: []byte("fedcba9876543210fedcba9876543210")}
+	assert.Error(t, encryption.Decode(item))
+}
+
+func Test_CompositeRecord_with_encrypted_attributes_should_be_persisted_reconstituted_and_queried(t *testing.T) {
+	var err error
+
+	encryption := Encryption{
+		Attributes:              []string{"some_value"},
+		DeterministicAttributes: []string{"partition_key"},
+		KeyProvider:             localKeyProvider,
+		DeterministicDataKey:    mustGenerateEncryptedDataKey(),
+	}
+
+	encryptedCompositeRecordsTable := Table[compositeRecord]{
+		Name:   compositeRecordsTableName,
+		Codecs: []ItemCodec{encryption},
+	}
+
+	partitionKeyValue := uuid.New().String()
+	record := compositeRecord{
+		PartitionKey: partitionKeyValue,
+		SortKey:      1,
+		SomeValue:    "user identifying data",
+	}
+
+	err = encryptedCompositeRecordsTable.Action(dynamodbClient).Persist(record)
+	assert.NoError(t, err)
+
+	encryptedKey := map[string]*dynamodb.AttributeValue{
+		"partition_key": {
+			S: aws.String(partitionKeyValue),
+		},
+		"sort_key": {
+			N: aws.String("1"),
+		},
+	}
+	err = encryption.Encode(encryptedKey)
+	assert.NoError(t, err)
+
+	getEventOutput, err := dynamodbClient.GetItem(
+		&dynamodb.GetItemInput{
+			TableName: aws.String(compositeRecordsTableName),
+			Key:       encryptedKey,
+		},
+	)
+	assert.NoError(t, err)
+	assert.True(t, isEncryptedAttribute(getEventOutput.Item["partition_key"]))
+	assert.True(t, isEncryptedAttribute(getEventOutput.Item["some_value"]))
+
+	actualRecord := compositeRecord{
+		PartitionKey: partitionKeyValue,
+		SortKey:      1,
+	}
+	err = encryptedCompositeRecordsTable.Action(dynamodbClient).Reconstitute(&actualRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, actualRecord)
+
+	partitionKey := DynamodbKey{
+		Name:  "partition_key",
+		Value: partitionKeyValue,
+		Type:  KeyTypeString,
+	}
+	actualRecords, _, err := encryptedCompositeRecordsTable.Action(dynamodbClient).Query(partitionKey, nil, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []compositeRecord{record}, actualRecords)
+}
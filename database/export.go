@@ -0,0 +1,214 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const importBatchSize = 100
+const maxJsonLineSize = 4 * 1024 * 1024
+
+// ItemTransformation rewrites an item during export or import. Returning a nil item skips it.
+type ItemTransformation func(item map[string]*dynamodb.AttributeValue) (transformed map[string]*dynamodb.AttributeValue, err error)
+
+type ExportOptions struct {
+	// Cursor resumes the export from the place reported by Progress
+	Cursor    *string
+	PageSize  int
+	Transform ItemTransformation
+	Progress  func(nextCursor *string) (err error)
+}
+
+type ImportOptions struct {
+	// SkipLines resumes the import from the place reported by Progress
+	SkipLines int
+	Transform ItemTransformation
+	Progress  func(importedLines int) (err error)
+}
+
+// Export writes the items of the table, exactly as they are stored, as JSON Lines in the AttributeValueJSON format.
+func (table TableAction[R]) Export(writer io.Writer, options ExportOptions) (err error) {
+	fetchPage := func(exclusiveStartKey map[string]*dynamodb.AttributeValue) (items []map[string]*dynamodb.AttributeValue, lastEvaluatedKey map[string]*dynamodb.AttributeValue, err error) {
+		output, err := table.scanPage(exclusiveStartKey, options.PageSize)
+		if err != nil {
+			return
+		}
+		return output.Items, output.LastEvaluatedKey, nil
+	}
+	err = exportPages(writer, options, fetchPage)
+	return
+}
+
+// ExportQuery writes the items of the given partition as JSON Lines in the AttributeValueJSON format.
+func (table TableAction[R]) ExportQuery(partitionKey DynamodbKey, writer io.Writer, options ExportOptions) (err error) {
+	keys := map[string]*dynamodb.AttributeValue{
+		partitionKey.Name: partitionKey.AttributeValue(),
+	}
+	err = table.encode(keys)
+	if err != nil {
+		return
+	}
+
+	fetchPage := func(exclusiveStartKey map[string]*dynamodb.AttributeValue) (items []map[string]*dynamodb.AttributeValue, lastEvaluatedKey map[string]*dynamodb.AttributeValue, err error) {
+		queryInput := &dynamodb.QueryInput{
+			TableName:              aws.String(table.Name),
+			KeyConditionExpression: aws.String(fmt.Sprintf("%s = :the_partition_key", partitionKey.Name)),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":the_partition_key": keys[partitionKey.Name],
+			},
+			ExclusiveStartKey: exclusiveStartKey,
+		}
+		if options.PageSize > 0 {
+			queryInput.Limit = aws.Int64(int64(options.PageSize))
+		}
+		output, err := table.DynamodbClient.Query(queryInput)
+		if err != nil {
+			return
+		}
+		return output.Items, output.LastEvaluatedKey, nil
+	}
+	err = exportPages(writer, options, fetchPage)
+	return
+}
+
+func exportPages(
+	writer io.Writer,
+	options ExportOptions,
+	fetchPage func(exclusiveStartKey map[string]*dynamodb.AttributeValue) (items []map[string]*dynamodb.AttributeValue, lastEvaluatedKey map[string]*dynamodb.AttributeValue, err error),
+) (err error) {
+	var exclusiveStartKey map[string]*dynamodb.AttributeValue
+	if options.Cursor != nil {
+		exclusiveStartKey, err = DecodeCursor(*options.Cursor)
+		if err != nil {
+			return
+		}
+	}
+
+	for {
+		var items []map[string]*dynamodb.AttributeValue
+		items, exclusiveStartKey, err = fetchPage(exclusiveStartKey)
+		if err != nil {
+			return
+		}
+
+		for _, item := range items {
+			if options.Transform != nil {
+				item, err = options.Transform(item)
+				if err != nil {
+					return
+				}
+				if item == nil {
+					continue
+				}
+			}
+			var line []byte
+			line, err = marshalJsonLine(item)
+			if err != nil {
+				return
+			}
+			_, err = writer.Write(line)
+			if err != nil {
+				return
+			}
+		}
+
+		var nextCursor *string
+		nextCursor, err = EncodeCursor(exclusiveStartKey)
+		if err != nil {
+			return
+		}
+		if options.Progress != nil {
+			err = options.Progress(nextCursor)
+			if err != nil {
+				return
+			}
+		}
+		if nextCursor == nil {
+			return
+		}
+	}
+}
+
+// Import reads JSON Lines written by Export and puts the items into the table in batches.
+func (table TableAction[R]) Import(reader io.Reader, options ImportOptions) (err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJsonLineSize)
+
+	readLines := 0
+	items := []map[string]*dynamodb.AttributeValue{}
+
+	flush := func() (err error) {
+		err = table.batchWriteItems(items)
+		if err != nil {
+			return
+		}
+		items = items[:0]
+		if options.Progress != nil {
+			err = options.Progress(readLines)
+		}
+		return
+	}
+
+	for scanner.Scan() {
+		readLines++
+		if readLines <= options.SkipLines || len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var item map[string]*dynamodb.AttributeValue
+		item, err = unmarshalJsonLine(scanner.Bytes())
+		if err != nil {
+			return
+		}
+		if options.Transform != nil {
+			item, err = options.Transform(item)
+			if err != nil {
+				return
+			}
+			if item == nil {
+				continue
+			}
+		}
+
+		items = append(items, item)
+		if len(items) == importBatchSize {
+			err = flush()
+			if err != nil {
+				return
+			}
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return
+	}
+
+	if len(items) > 0 || readLines > options.SkipLines {
+		err = flush()
+	}
+	return
+}
+
+func marshalJsonLine(item map[string]*dynamodb.AttributeValue) (line []byte, err error) {
+	line, err = json.Marshal(mapToJson(item))
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	return
+}
+
+func unmarshalJsonLine(line []byte) (item map[string]*dynamodb.AttributeValue, err error) {
+	itemInJson := map[string]*AttributeValueJSON{}
+	err = json.Unmarshal(line, &itemInJson)
+	if err != nil {
+		return
+	}
+	item = mapFromJSON(itemInJson)
+	return
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JsonLine_should_keep_the_item_intact(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"partition_key": {
+			S: aws.String("partition key"),
+		},
+		"sort_key": {
+			N: aws.String("42"),
+		},
+		"some_value": {
+			M: map[string]*dynamodb.AttributeValue{
+				"flag": {
+					BOOL: aws.Bool(true),
+				},
+				"tags": {
+					SS: []*string{aws.String("a"), aws.String("b")},
+				},
+			},
+		},
+	}
+
+	line, err := marshalJsonLine(item)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasSuffix(line, []byte("\n")))
+	assert.Equal(t, 1, bytes.Count(line, []byte("\n")))
+
+	actualItem, err := unmarshalJsonLine(bytes.TrimSuffix(line, []byte("\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, item, actualItem)
+}
+
+func Test_CompositeRecords_should_be_exported_and_imported_back_with_a_transformation(t *testing.T) {
+	var err error
+
+	partitionKeyValue := uuid.New().String()
+	partitionKey := DynamodbKey{
+		Name:  "partition_key",
+		Value: partitionKeyValue,
+		Type:  KeyTypeString,
+	}
+
+	records := []compositeRecord{}
+	for sortKey := 1; sortKey <= 30; sortKey++ {
+		records = append(records, compositeRecord{
+			PartitionKey: partitionKeyValue,
+			SortKey:      sortKey,
+			SomeValue:    "some value",
+		})
+	}
+	err = compositeRecordsTable.Action(dynamodbClient).BatchPersist(records)
+	assert.NoError(t, err)
+
+	cursors := []*string{}
+	var exported bytes.Buffer
+	err = compositeRecordsTable.Action(dynamodbClient).ExportQuery(partitionKey, &exported, ExportOptions{
+		PageSize: 10,
+		Progress: func(nextCursor *string) error {
+			cursors = append(cursors, nextCursor)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 30, strings.Count(exported.String(), "\n"))
+	assert.Nil(t, cursors[len(cursors)-1])
+
+	importedPartitionKeyValue := uuid.New().String()
+	importedLines := 0
+	err = compositeRecordsTable.Action(dynamodbClient).Import(&exported, ImportOptions{
+		SkipLines: 5,
+		Transform: func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+			item["partition_key"] = &dynamodb.AttributeValue{S: aws.String(importedPartitionKeyValue)}
+			item["some_value"] = &dynamodb.AttributeValue{S: aws.String("migrated value")}
+			return item, nil
+		},
+		Progress: func(lines int) error {
+			importedLines = lines
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 30, importedLines)
+
+	importedPartitionKey := DynamodbKey{
+		Name:  "partition_key",
+		Value: importedPartitionKeyValue,
+		Type:  KeyTypeString,
+	}
+	actualRecords, nextCursor, err := compositeRecordsTable.Action(dynamodbClient).Query(importedPartitionKey, nil, 100)
+	assert.NoError(t, err)
+	assert.Nil(t, nextCursor)
+	assert.Len(t, actualRecords, 25)
+	for _, actualRecord := range actualRecords {
+		assert.Equal(t, "migrated value", actualRecord.SomeValue)
+	}
+}
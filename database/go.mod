@@ -7,6 +7,7 @@ toolchain go1.24.4
 require (
 	github.com/aws/aws-sdk-go v1.50.28
 	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.17.11
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/exp v0.0.0-20250218142911-aa4b98e5adaa
 )
@@ -17,3 +18,10 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+require (
+	github.com/aws/aws-lambda-go v1.47.0
+	github.com/gitlotto/common/batcher v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/gitlotto/common/batcher => ../batcher
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+const dataKeySize = 32
+
+var ErrInvalidEncryptedDataKey = fmt.Errorf("invalid encrypted data key")
+
+// KeyProvider issues data keys for envelope encryption and decrypts them back.
+type KeyProvider interface {
+	GenerateDataKey() (plaintextKey []byte, encryptedKey []byte, err error)
+	DecryptDataKey(encryptedKey []byte) (plaintextKey []byte, err error)
+}
+
+// LocalKeyProvider wraps data keys with a master key kept in memory. It is meant for tests and local development.
+type LocalKeyProvider struct {
+	MasterKey []byte
+}
+
+func (provider LocalKeyProvider) GenerateDataKey() (plaintextKey []byte, encryptedKey []byte, err error) {
+	plaintextKey = make([]byte, dataKeySize)
+	_, err = rand.Read(plaintextKey)
+	if err != nil {
+		return
+	}
+
+	masterCipher, err := newGcm(provider.MasterKey)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, masterCipher.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return
+	}
+	encryptedKey = masterCipher.Seal(nonce, nonce, plaintextKey, nil)
+	return
+}
+
+func (provider LocalKeyProvider) DecryptDataKey(encryptedKey []byte) (plaintextKey []byte, err error) {
+	masterCipher, err := newGcm(provider.MasterKey)
+	if err != nil {
+		return
+	}
+	nonceSize := masterCipher.NonceSize()
+	if len(encryptedKey) < nonceSize {
+		err = ErrInvalidEncryptedDataKey
+		return
+	}
+	plaintextKey, err = masterCipher.Open(nil, encryptedKey[:nonceSize], encryptedKey[nonceSize:], nil)
+	return
+}
+
+type KmsKeyProvider struct {
+	kmsClient *kms.KMS
+	keyId     string
+}
+
+func NewKmsKeyProvider(session *session.Session, keyId string) *KmsKeyProvider {
+	return &KmsKeyProvider{
+		kmsClient: kms.New(session),
+		keyId:     keyId,
+	}
+}
+
+func (provider *KmsKeyProvider) GenerateDataKey() (plaintextKey []byte, encryptedKey []byte, err error) {
+	output, err := provider.kmsClient.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:   aws.String(provider.keyId),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return
+	}
+	plaintextKey = output.Plaintext
+	encryptedKey = output.CiphertextBlob
+	return
+}
+
+func (provider *KmsKeyProvider) DecryptDataKey(encryptedKey []byte) (plaintextKey []byte, err error) {
+	output, err := provider.kmsClient.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(provider.keyId),
+		CiphertextBlob: encryptedKey,
+	})
+	if err != nil {
+		return
+	}
+	plaintextKey = output.Plaintext
+	return
+}
+
+func newGcm(key []byte) (gcm cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	gcm, err = cipher.NewGCM(block)
+	return
+}
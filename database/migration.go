@@ -0,0 +1,405 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const schemaVersionAttributeName = "schema_version"
+const appliedMigrationsAttributeName = "applied_migrations"
+const migrationCursorAttributeName = "migration_cursor"
+const migrationItemAttempts = 3
+
+var ErrMigrationInterrupted = fmt.Errorf("migration has been interrupted, run it again to resume")
+
+func ErrDuplicatedMigrationVersion(version int) error {
+	return fmt.Errorf("migration version %d is used more than once", version)
+}
+
+// Migration rewrites a single item, already decoded by the codecs of the table, from the previous schema version to Version.
+type Migration struct {
+	Version int
+	Migrate func(item map[string]*dynamodb.AttributeValue) (err error)
+}
+
+// MigrationRunner applies migrations to every item of the table. Each item keeps its schema version in
+// the schema_version attribute. The applied migrations and the progress of the scan are kept in the
+// metadata item, so an interrupted run resumes where it stopped.
+// Only the attributes changed by the migrations are written, on condition that every attribute that has been
+// read is still the same. VersionAttribute optionally names a numeric attribute incremented by every write to
+// the table, the condition then only compares the version, which keeps it small for items of any size.
+type MigrationRunner[R Record] struct {
+	TableAction[R]
+	MetadataKey      PrimaryKey
+	Migrations       []Migration
+	PageSize         int
+	VersionAttribute string
+}
+
+// Migration creates the runner of the migrations. The version attribute may be empty if the table has none.
+func (table Table[R]) Migration(dynamodbClient *dynamodb.DynamoDB, metadataKey PrimaryKey, migrations []Migration, versionAttribute string) MigrationRunner[R] {
+	return MigrationRunner[R]{
+		TableAction:      table.Action(dynamodbClient),
+		MetadataKey:      metadataKey,
+		Migrations:       migrations,
+		VersionAttribute: versionAttribute,
+	}
+}
+
+// Run migrates the items until all of them are migrated or the deadline passes. A zero deadline means no deadline.
+func (runner MigrationRunner[R]) Run(deadline time.Time) (err error) {
+	migrations, err := sortedMigrations(runner.Migrations)
+	if err != nil || len(migrations) == 0 {
+		return
+	}
+
+	metadata, err := runner.DynamodbClient.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(runner.Name),
+		Key:            runner.metadataKeys(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return
+	}
+
+	appliedVersions := map[string]bool{}
+	if appliedMigrations, ok := metadata.Item[appliedMigrationsAttributeName]; ok {
+		for _, version := range appliedMigrations.NS {
+			appliedVersions[*version] = true
+		}
+	}
+	pendingVersions := []*string{}
+	for _, migration := range migrations {
+		version := strconv.Itoa(migration.Version)
+		if !appliedVersions[version] {
+			pendingVersions = append(pendingVersions, aws.String(version))
+		}
+	}
+	if len(pendingVersions) == 0 {
+		return
+	}
+
+	var exclusiveStartKey map[string]*dynamodb.AttributeValue
+	if cursor, ok := metadata.Item[migrationCursorAttributeName]; ok && cursor.S != nil {
+		exclusiveStartKey, err = DecodeCursor(*cursor.S)
+		if err != nil {
+			return
+		}
+	}
+
+	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return ErrMigrationInterrupted
+		}
+
+		var page *dynamodb.ScanOutput
+		page, err = runner.scanPage(exclusiveStartKey, runner.PageSize)
+		if err != nil {
+			return
+		}
+
+		for _, item := range page.Items {
+			if runner.isMetadataItem(item) {
+				continue
+			}
+			err = runner.migrateItem(item, migrations)
+			if err != nil {
+				return
+			}
+		}
+
+		exclusiveStartKey = page.LastEvaluatedKey
+		if len(exclusiveStartKey) == 0 {
+			break
+		}
+
+		var cursor *string
+		cursor, err = EncodeCursor(exclusiveStartKey)
+		if err != nil {
+			return
+		}
+		_, err = runner.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+			TableName:        aws.String(runner.Name),
+			Key:              runner.metadataKeys(),
+			UpdateExpression: aws.String("SET " + migrationCursorAttributeName + " = :cursor"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":cursor": {
+					S: cursor,
+				},
+			},
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = runner.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(runner.Name),
+		Key:              runner.metadataKeys(),
+		UpdateExpression: aws.String("ADD " + appliedMigrationsAttributeName + " :versions REMOVE " + migrationCursorAttributeName),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":versions": {
+				NS: pendingVersions,
+			},
+		},
+	})
+	return
+}
+
+func (runner MigrationRunner[R]) migrateItem(item map[string]*dynamodb.AttributeValue, migrations []Migration) (err error) {
+	for attempt := 0; attempt < migrationItemAttempts; attempt++ {
+		if attempt > 0 {
+			var latest *dynamodb.GetItemOutput
+			latest, err = runner.DynamodbClient.GetItem(&dynamodb.GetItemInput{
+				TableName:      aws.String(runner.Name),
+				Key:            runner.keysOf(item),
+				ConsistentRead: aws.Bool(true),
+			})
+			if err != nil {
+				return
+			}
+			if len(latest.Item) == 0 {
+				return nil
+			}
+			item = latest.Item
+		}
+
+		var migratedItem map[string]*dynamodb.AttributeValue
+		migratedItem, err = runner.applyMigrations(item, migrations)
+		if err != nil || migratedItem == nil {
+			return
+		}
+
+		var input *dynamodb.UpdateItemInput
+		input, err = runner.migrationUpdate(item, migratedItem)
+		if err != nil {
+			return
+		}
+		_, err = runner.DynamodbClient.UpdateItem(input)
+		if _, conditionalCheckFailed := err.(*dynamodb.ConditionalCheckFailedException); !conditionalCheckFailed {
+			return
+		}
+	}
+	return
+}
+
+// applyMigrations returns the migrated copy of the item or nil if the item is already up to date.
+func (runner MigrationRunner[R]) applyMigrations(item map[string]*dynamodb.AttributeValue, migrations []Migration) (migratedItem map[string]*dynamodb.AttributeValue, err error) {
+	currentVersion := 0
+	if schemaVersion, ok := item[schemaVersionAttributeName]; ok && schemaVersion.N != nil {
+		currentVersion, err = strconv.Atoi(*schemaVersion.N)
+		if err != nil {
+			return
+		}
+	}
+	targetVersion := migrations[len(migrations)-1].Version
+	if currentVersion >= targetVersion {
+		return
+	}
+
+	migratedItem = mapFromJSON(mapToJson(item))
+	err = runner.decode(migratedItem)
+	if err != nil {
+		return
+	}
+	for _, migration := range migrations {
+		if migration.Version <= currentVersion {
+			continue
+		}
+		err = migration.Migrate(migratedItem)
+		if err != nil {
+			return
+		}
+	}
+	err = runner.encode(migratedItem)
+	if err != nil {
+		return
+	}
+	migratedItem[schemaVersionAttributeName] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.Itoa(targetVersion)),
+	}
+	return
+}
+
+func (runner MigrationRunner[R]) metadataKeys() map[string]*dynamodb.AttributeValue {
+	keys := map[string]*dynamodb.AttributeValue{
+		runner.MetadataKey.PartitionKey.Name: runner.MetadataKey.PartitionKey.AttributeValue(),
+	}
+	if runner.MetadataKey.SortKey != nil {
+		keys[runner.MetadataKey.SortKey.Name] = runner.MetadataKey.SortKey.AttributeValue()
+	}
+	return keys
+}
+
+func (runner MigrationRunner[R]) keysOf(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	keys := map[string]*dynamodb.AttributeValue{}
+	for name := range runner.metadataKeys() {
+		keys[name] = item[name]
+	}
+	return keys
+}
+
+func (runner MigrationRunner[R]) isMetadataItem(item map[string]*dynamodb.AttributeValue) bool {
+	for name, value := range runner.metadataKeys() {
+		if !sameKeyValue(item[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameKeyValue(first *dynamodb.AttributeValue, second *dynamodb.AttributeValue) bool {
+	if first == nil || second == nil {
+		return false
+	}
+	return aws.StringValue(first.S) == aws.StringValue(second.S) && aws.StringValue(first.N) == aws.StringValue(second.N)
+}
+
+// migrationUpdate only sets and removes the attributes changed by the migrations, so attributes added to the
+// item since it had been read are kept.
+func (runner MigrationRunner[R]) migrationUpdate(item map[string]*dynamodb.AttributeValue, migratedItem map[string]*dynamodb.AttributeValue) (input *dynamodb.UpdateItemInput, err error) {
+	conditionExpression, names, values := runner.sameItemCondition(item)
+	err = runner.incrementVersion(migratedItem, item)
+	if err != nil {
+		return
+	}
+
+	keys := runner.keysOf(item)
+	attributeNames := make([]string, 0, len(migratedItem))
+	for name := range migratedItem {
+		attributeNames = append(attributeNames, name)
+	}
+	for name := range item {
+		if _, kept := migratedItem[name]; !kept {
+			attributeNames = append(attributeNames, name)
+		}
+	}
+	sort.Strings(attributeNames)
+
+	sets := []string{}
+	removes := []string{}
+	for i, name := range attributeNames {
+		if _, isKey := keys[name]; isKey {
+			continue
+		}
+		migratedValue, kept := migratedItem[name]
+		if kept && reflect.DeepEqual(migratedValue, item[name]) {
+			continue
+		}
+		namePlaceholder := fmt.Sprintf("#u%d", i)
+		names[namePlaceholder] = aws.String(name)
+		if !kept {
+			removes = append(removes, namePlaceholder)
+			continue
+		}
+		valuePlaceholder := fmt.Sprintf(":u%d", i)
+		values[valuePlaceholder] = migratedValue
+		sets = append(sets, fmt.Sprintf("%s = %s", namePlaceholder, valuePlaceholder))
+	}
+
+	updateExpression := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removes, ", ")
+	}
+	input = &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(runner.Name),
+		Key:                       keys,
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String(conditionExpression),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	return
+}
+
+// sameItemCondition makes sure that nobody changed the item since it had been read. Without a version attribute
+// every attribute that has been read is compared, otherwise only the key, the schema version and the version.
+func (runner MigrationRunner[R]) sameItemCondition(item map[string]*dynamodb.AttributeValue) (conditionExpression string, names map[string]*string, values map[string]*dynamodb.AttributeValue) {
+	names = map[string]*string{
+		"#schema_version": aws.String(schemaVersionAttributeName),
+	}
+	values = map[string]*dynamodb.AttributeValue{}
+	conditions := []string{}
+
+	if runner.VersionAttribute == "" {
+		attributeNames := make([]string, 0, len(item))
+		for name := range item {
+			attributeNames = append(attributeNames, name)
+		}
+		sort.Strings(attributeNames)
+		for i, name := range attributeNames {
+			namePlaceholder := fmt.Sprintf("#a%d", i)
+			valuePlaceholder := fmt.Sprintf(":v%d", i)
+			names[namePlaceholder] = aws.String(name)
+			values[valuePlaceholder] = item[name]
+			conditions = append(conditions, fmt.Sprintf("%s = %s", namePlaceholder, valuePlaceholder))
+		}
+		if _, ok := item[schemaVersionAttributeName]; !ok {
+			conditions = append(conditions, "attribute_not_exists(#schema_version)")
+		} else {
+			delete(names, "#schema_version")
+		}
+		conditionExpression = strings.Join(conditions, " AND ")
+		return
+	}
+
+	names["#partition_key"] = aws.String(runner.MetadataKey.PartitionKey.Name)
+	conditions = append(conditions, "attribute_exists(#partition_key)")
+
+	if schemaVersion, ok := item[schemaVersionAttributeName]; ok {
+		values[":schema_version"] = schemaVersion
+		conditions = append(conditions, "#schema_version = :schema_version")
+	} else {
+		conditions = append(conditions, "attribute_not_exists(#schema_version)")
+	}
+
+	names["#version"] = aws.String(runner.VersionAttribute)
+	if version, ok := item[runner.VersionAttribute]; ok {
+		values[":version"] = version
+		conditions = append(conditions, "#version = :version")
+	} else {
+		conditions = append(conditions, "attribute_not_exists(#version)")
+	}
+
+	conditionExpression = strings.Join(conditions, " AND ")
+	return
+}
+
+// incrementVersion makes writers that have read the item before the migration fail their own version check.
+func (runner MigrationRunner[R]) incrementVersion(migratedItem map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) (err error) {
+	if runner.VersionAttribute == "" {
+		return
+	}
+	version := 0
+	if current, ok := item[runner.VersionAttribute]; ok && current.N != nil {
+		version, err = strconv.Atoi(*current.N)
+		if err != nil {
+			return
+		}
+	}
+	migratedItem[runner.VersionAttribute] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.Itoa(version + 1)),
+	}
+	return
+}
+
+func sortedMigrations(migrations []Migration) (sorted []Migration, err error) {
+	sorted = append([]Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			err = ErrDuplicatedMigrationVersion(sorted[i].Version)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,310 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func renameSomeValueMigration(partitionKeyValue string) Migration {
+	return Migration{
+		Version: 1,
+		Migrate: func(item map[string]*dynamodb.AttributeValue) error {
+			if aws.StringValue(item["partition_key"].S) != partitionKeyValue {
+				return nil
+			}
+			item["some_value"] = item["old_value"]
+			delete(item, "old_value")
+			return nil
+		},
+	}
+}
+
+func suffixSomeValueMigration(partitionKeyValue string) Migration {
+	return Migration{
+		Version: 2,
+		Migrate: func(item map[string]*dynamodb.AttributeValue) error {
+			if aws.StringValue(item["partition_key"].S) != partitionKeyValue {
+				return nil
+			}
+			item["some_value"] = &dynamodb.AttributeValue{S: aws.String(aws.StringValue(item["some_value"].S) + " v2")}
+			return nil
+		},
+	}
+}
+
+func Test_Migrations_should_be_applied_in_order_from_the_current_schema_version(t *testing.T) {
+	runner := compositeRecordsTable.Migration(dynamodbClient, PrimaryKey{}, nil, "")
+	migrations, err := sortedMigrations([]Migration{suffixSomeValueMigration("partition"), renameSomeValueMigration("partition")})
+	assert.NoError(t, err)
+
+	item := map[string]*dynamodb.AttributeValue{
+		"partition_key": {S: aws.String("partition")},
+		"old_value":     {S: aws.String("value")},
+	}
+	migratedItem, err := runner.applyMigrations(item, migrations)
+	assert.NoError(t, err)
+
+	expectedItem := map[string]*dynamodb.AttributeValue{
+		"partition_key":  {S: aws.String("partition")},
+		"some_value":     {S: aws.String("value v2")},
+		"schema_version": {N: aws.String("2")},
+	}
+	assert.Equal(t, expectedItem, migratedItem)
+	assert.Contains(t, item, "old_value")
+
+	alreadyMigratedItem, err := runner.applyMigrations(migratedItem, migrations)
+	assert.NoError(t, err)
+	assert.Nil(t, alreadyMigratedItem)
+
+	itemOfVersionOne := map[string]*dynamodb.AttributeValue{
+		"partition_key":  {S: aws.String("partition")},
+		"some_value":     {S: aws.String("value")},
+		"schema_version": {N: aws.String("1")},
+	}
+	migratedItemOfVersionOne, err := runner.applyMigrations(itemOfVersionOne, migrations)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedItem, migratedItemOfVersionOne)
+}
+
+func Test_Migrations_should_not_share_a_version(t *testing.T) {
+	_, err := sortedMigrations([]Migration{renameSomeValueMigration(""), renameSomeValueMigration("")})
+	assert.Equal(t, ErrDuplicatedMigrationVersion(1), err)
+}
+
+func Test_Migrations_should_compare_every_read_attribute_without_a_version_attribute(t *testing.T) {
+	metadataKey := PrimaryKey{
+		PartitionKey: DynamodbKey{
+			Name:  "partition_key",
+			Value: "migrations",
+			Type:  KeyTypeString,
+		},
+	}
+	runner := compositeRecordsTable.Migration(dynamodbClient, metadataKey, nil, "")
+
+	item := map[string]*dynamodb.AttributeValue{
+		"partition_key": {S: aws.String("partition")},
+		"old_value":     {S: aws.String("value")},
+	}
+	conditionExpression, names, values := runner.sameItemCondition(item)
+	assert.Equal(t, "#a0 = :v0 AND #a1 = :v1 AND attribute_not_exists(#schema_version)", conditionExpression)
+	assert.Equal(t, map[string]*string{"#a0": aws.String("old_value"), "#a1": aws.String("partition_key"), "#schema_version": aws.String("schema_version")}, names)
+	assert.Equal(t, map[string]*dynamodb.AttributeValue{":v0": {S: aws.String("value")}, ":v1": {S: aws.String("partition")}}, values)
+
+	migratedItem := map[string]*dynamodb.AttributeValue{
+		"partition_key":  {S: aws.String("partition")},
+		"some_value":     {S: aws.String("value")},
+		"schema_version": {N: aws.String("1")},
+	}
+	input, err := runner.migrationUpdate(item, migratedItem)
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #u2 = :u2, #u3 = :u3 REMOVE #u0", *input.UpdateExpression)
+	assert.Equal(t, map[string]*dynamodb.AttributeValue{"partition_key": {S: aws.String("partition")}}, input.Key)
+	assert.Equal(t, aws.String("old_value"), input.ExpressionAttributeNames["#u0"])
+	assert.Equal(t, aws.String("schema_version"), input.ExpressionAttributeNames["#u2"])
+	assert.Equal(t, aws.String("some_value"), input.ExpressionAttributeNames["#u3"])
+}
+
+func Test_Migrations_should_only_compare_the_versions_with_a_version_attribute(t *testing.T) {
+	metadataKey := PrimaryKey{
+		PartitionKey: DynamodbKey{
+			Name:  "partition_key",
+			Value: "migrations",
+			Type:  KeyTypeString,
+		},
+	}
+	runner := compositeRecordsTable.Migration(dynamodbClient, metadataKey, nil, "version")
+
+	item := map[string]*dynamodb.AttributeValue{
+		"partition_key":  {S: aws.String("partition")},
+		"some_list":      {L: []*dynamodb.AttributeValue{{S: aws.String("value")}}},
+		"schema_version": {N: aws.String("1")},
+		"version":        {N: aws.String("7")},
+	}
+	conditionExpression, _, values := runner.sameItemCondition(item)
+	assert.Equal(t, "attribute_exists(#partition_key) AND #schema_version = :schema_version AND #version = :version", conditionExpression)
+	assert.Equal(t, map[string]*dynamodb.AttributeValue{":schema_version": {N: aws.String("1")}, ":version": {N: aws.String("7")}}, values)
+
+	migratedItem := map[string]*dynamodb.AttributeValue{}
+	err := runner.incrementVersion(migratedItem, item)
+	assert.NoError(t, err)
+	assert.Equal(t, &dynamodb.AttributeValue{N: aws.String("8")}, migratedItem["version"])
+}
+
+func Test_MigrationRunner_should_keep_a_change_made_between_the_read_and_the_write(t *testing.T) {
+	var err error
+
+	partitionKeyValue := uuid.New().String()
+	keys := map[string]*dynamodb.AttributeValue{
+		"partition_key": {S: aws.String(partitionKeyValue)},
+		"sort_key":      {N: aws.String("1")},
+	}
+	_, err = dynamodbClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(compositeRecordsTableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"partition_key": keys["partition_key"],
+			"sort_key":      keys["sort_key"],
+			"old_value":     {S: aws.String("value")},
+			"status":        {S: aws.String("OPEN")},
+		},
+	})
+	assert.NoError(t, err)
+
+	concurrentlyChanged := false
+	changingMigration := renameSomeValueMigration(partitionKeyValue)
+	rename := changingMigration.Migrate
+	changingMigration.Migrate = func(item map[string]*dynamodb.AttributeValue) error {
+		if aws.StringValue(item["partition_key"].S) == partitionKeyValue && !concurrentlyChanged {
+			concurrentlyChanged = true
+			_, err := dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+				TableName:        aws.String(compositeRecordsTableName),
+				Key:              keys,
+				UpdateExpression: aws.String("SET #status = :status"),
+				ExpressionAttributeNames: map[string]*string{
+					"#status": aws.String("status"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":status": {S: aws.String("CLOSED")},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return rename(item)
+	}
+
+	metadataKey := PrimaryKey{
+		PartitionKey: DynamodbKey{
+			Name:  "partition_key",
+			Value: "migrations#" + uuid.New().String(),
+			Type:  KeyTypeString,
+		},
+		SortKey: &DynamodbKey{
+			Name:  "sort_key",
+			Value: "0",
+			Type:  KeyTypeNumber,
+		},
+	}
+	runner := compositeRecordsTable.Migration(dynamodbClient, metadataKey, []Migration{changingMigration}, "")
+	err = runner.Run(time.Time{})
+	assert.NoError(t, err)
+	assert.True(t, concurrentlyChanged)
+
+	actualItem, err := dynamodbClient.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(compositeRecordsTableName),
+		Key:            keys,
+		ConsistentRead: aws.Bool(true),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, aws.String("CLOSED"), actualItem.Item["status"].S)
+	assert.Equal(t, aws.String("value"), actualItem.Item["some_value"].S)
+	assert.NotContains(t, actualItem.Item, "old_value")
+	assert.Equal(t, aws.String("1"), actualItem.Item["schema_version"].N)
+}
+
+func Test_MigrationRunner_should_migrate_all_items_and_record_the_applied_versions(t *testing.T) {
+	var err error
+
+	partitionKeyValue := uuid.New().String()
+	for sortKey := 1; sortKey <= 3; sortKey++ {
+		_, err = dynamodbClient.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String(compositeRecordsTableName),
+			Item: map[string]*dynamodb.AttributeValue{
+				"partition_key": {S: aws.String(partitionKeyValue)},
+				"sort_key":      {N: aws.String(strconv.Itoa(sortKey))},
+				"old_value":     {S: aws.String("value")},
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	metadataKey := PrimaryKey{
+		PartitionKey: DynamodbKey{
+			Name:  "partition_key",
+			Value: "migrations#" + uuid.New().String(),
+			Type:  KeyTypeString,
+		},
+		SortKey: &DynamodbKey{
+			Name:  "sort_key",
+			Value: "0",
+			Type:  KeyTypeNumber,
+		},
+	}
+	// the first migration of an item of the partition outlives the deadline, so the run stops after its page
+	deadline := time.Now().Add(time.Second * 2)
+	migratedInFirstRun := map[string]bool{}
+	interruptingMigration := renameSomeValueMigration(partitionKeyValue)
+	rename := interruptingMigration.Migrate
+	interruptingMigration.Migrate = func(item map[string]*dynamodb.AttributeValue) error {
+		if aws.StringValue(item["partition_key"].S) == partitionKeyValue {
+			if len(migratedInFirstRun) == 0 {
+				time.Sleep(time.Until(deadline))
+			}
+			migratedInFirstRun[*item["sort_key"].N] = true
+		}
+		return rename(item)
+	}
+
+	runner := compositeRecordsTable.Migration(dynamodbClient, metadataKey, []Migration{interruptingMigration, suffixSomeValueMigration(partitionKeyValue)}, "")
+	runner.PageSize = 2
+
+	err = runner.Run(deadline)
+	assert.ErrorIs(t, err, ErrMigrationInterrupted)
+	assert.NotEmpty(t, migratedInFirstRun)
+	assert.Less(t, len(migratedInFirstRun), 3)
+
+	metadata, err := dynamodbClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(compositeRecordsTableName),
+		Key:       runner.metadataKeys(),
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, metadata.Item, migrationCursorAttributeName)
+	assert.NotContains(t, metadata.Item, appliedMigrationsAttributeName)
+
+	// the items before the cursor are put back to the old schema, a run starting over would migrate them again
+	for sortKey := range migratedInFirstRun {
+		_, err = dynamodbClient.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String(compositeRecordsTableName),
+			Item: map[string]*dynamodb.AttributeValue{
+				"partition_key": {S: aws.String(partitionKeyValue)},
+				"sort_key":      {N: aws.String(sortKey)},
+				"some_value":    {S: aws.String("value before the cursor")},
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	runner.Migrations = []Migration{renameSomeValueMigration(partitionKeyValue), suffixSomeValueMigration(partitionKeyValue)}
+	err = runner.Run(time.Time{})
+	assert.NoError(t, err)
+
+	partitionKey := DynamodbKey{
+		Name:  "partition_key",
+		Value: partitionKeyValue,
+		Type:  KeyTypeString,
+	}
+	actualRecords, _, err := compositeRecordsTable.Action(dynamodbClient).Query(partitionKey, nil, 10)
+	assert.NoError(t, err)
+	assert.Len(t, actualRecords, 3)
+	for _, actualRecord := range actualRecords {
+		if migratedInFirstRun[strconv.Itoa(actualRecord.SortKey)] {
+			assert.Equal(t, "value before the cursor", actualRecord.SomeValue)
+		} else {
+			assert.Equal(t, "value v2", actualRecord.SomeValue)
+		}
+	}
+
+	metadata, err = dynamodbClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(compositeRecordsTableName),
+		Key:       runner.metadataKeys(),
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []*string{aws.String("1"), aws.String("2")}, metadata.Item[appliedMigrationsAttributeName].NS)
+	assert.NotContains(t, metadata.Item, migrationCursorAttributeName)
+}
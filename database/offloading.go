@@ -0,0 +1,98 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const offloadedBlobAttributeName = "offloaded_blob"
+
+// Offloading moves the given attributes to the blob store once their size exceeds the threshold.
+// The attribute is replaced by a pointer to the blob and is restored on Decode.
+type Offloading struct {
+	Attributes []string
+	Threshold  int
+	Store      BlobStore
+	KeyPrefix  string
+}
+
+func (offloading Offloading) Encode(item map[string]*dynamodb.AttributeValue) (err error) {
+	for _, attributeName := range offloading.Attributes {
+		attribute, ok := item[attributeName]
+		if !ok || attribute == nil || isOffloadedBlobPointer(attribute) {
+			continue
+		}
+
+		var attributeInJson []byte
+		attributeInJson, err = json.Marshal(toJson(attribute))
+		if err != nil {
+			return
+		}
+		if len(attributeInJson) <= offloading.Threshold {
+			continue
+		}
+
+		var blob []byte
+		blob, err = gzipCompress(attributeInJson)
+		if err != nil {
+			return
+		}
+
+		blobHash := sha256.Sum256(blob)
+		blobKey := offloading.KeyPrefix + hex.EncodeToString(blobHash[:])
+		err = offloading.Store.Upload(blobKey, blob)
+		if err != nil {
+			return
+		}
+
+		item[attributeName] = &dynamodb.AttributeValue{
+			M: map[string]*dynamodb.AttributeValue{
+				offloadedBlobAttributeName: {
+					S: aws.String(blobKey),
+				},
+			},
+		}
+	}
+	return
+}
+
+func (offloading Offloading) Decode(item map[string]*dynamodb.AttributeValue) (err error) {
+	for _, attributeName := range offloading.Attributes {
+		attribute, ok := item[attributeName]
+		if !ok || !isOffloadedBlobPointer(attribute) {
+			continue
+		}
+
+		var blob []byte
+		blob, err = offloading.Store.Download(*attribute.M[offloadedBlobAttributeName].S)
+		if err != nil {
+			return
+		}
+
+		var attributeInJson []byte
+		attributeInJson, err = gzipDecompress(blob)
+		if err != nil {
+			return
+		}
+
+		var restoredAttribute AttributeValueJSON
+		err = json.Unmarshal(attributeInJson, &restoredAttribute)
+		if err != nil {
+			return
+		}
+		item[attributeName] = fromJson(&restoredAttribute)
+	}
+	return
+}
+
+func isOffloadedBlobPointer(attribute *dynamodb.AttributeValue) bool {
+	if attribute == nil || len(attribute.M) != 1 {
+		return false
+	}
+	blobKey, ok := attribute.M[offloadedBlobAttributeName]
+	return ok && blobKey != nil && blobKey.S != nil
+}
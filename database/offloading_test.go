@@ -0,0 +1,162 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Offloading_should_replace_a_big_attribute_with_a_pointer_and_restore_it(t *testing.T) {
+	var err error
+
+	blobStore := NewInMemoryBlobStore()
+	offloading := Offloading{
+		Attributes: []string{"some_value"},
+		Threshold:  64,
+		Store:      blobStore,
+		KeyPrefix:  "offloaded/",
+	}
+
+	bigValue := strings.Repeat("big value ", 100)
+	item := map[string]*dynamodb.AttributeValue{
+		"partition_key": {
+			S: aws.String("partition key"),
+		},
+		"some_value": {
+			S: aws.String(bigValue),
+		},
+	}
+
+	err = offloading.Encode(item)
+	assert.NoError(t, err)
+	assert.Nil(t, item["some_value"].S)
+	assert.True(t, isOffloadedBlobPointer(item["some_value"]))
+	assert.True(t, strings.HasPrefix(*item["some_value"].M[offloadedBlobAttributeName].S, "offloaded/"))
+
+	err = offloading.Decode(item)
+	assert.NoError(t, err)
+
+	expectedItem := map[string]*dynamodb.AttributeValue{
+		"partition_key": {
+			S: aws.String("partition key"),
+		},
+		"some_value": {
+			S: aws.String(bigValue),
+		},
+	}
+	assert.Equal(t, expectedItem, item)
+}
+
+func Test_Offloading_should_keep_a_small_attribute_inline(t *testing.T) {
+	var err error
+
+	offloading := Offloading{
+		Attributes: []string{"some_value"},
+		Threshold:  64,
+		Store:      NewInMemoryBlobStore(),
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"some_value": {
+			S: aws.String("small value"),
+		},
+	}
+
+	err = offloading.Encode(item)
+	assert.NoError(t, err)
+
+	expectedItem := map[string]*dynamodb.AttributeValue{
+		"some_value": {
+			S: aws.String("small value"),
+		},
+	}
+	assert.Equal(t, expectedItem, item)
+}
+
+func Test_Offloading_should_work_with_the_file_system_blob_store(t *testing.T) {
+	var err error
+
+	offloading := Offloading{
+		Attributes: []string{"some_value"},
+		Threshold:  0,
+		Store:      FileSystemBlobStore{Directory: t.TempDir()},
+		KeyPrefix:  "records/",
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"some_value": {
+			L: []*dynamodb.AttributeValue{
+				{S: aws.String("first")},
+				{N: aws.String("2")},
+			},
+		},
+	}
+
+	err = offloading.Encode(item)
+	assert.NoError(t, err)
+	assert.True(t, isOffloadedBlobPointer(item["some_value"]))
+
+	err = offloading.Decode(item)
+	assert.NoError(t, err)
+
+	expectedItem := map[string]*dynamodb.AttributeValue{
+		"some_value": {
+			L: []*dynamodb.AttributeValue{
+				{S: aws.String("first")},
+				{N: aws.String("2")},
+			},
+		},
+	}
+	assert.Equal(t, expectedItem, item)
+
+	_, err = FileSystemBlobStore{Directory: t.TempDir()}.Download("records/unknown")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+}
+
+func Test_SimpleRecord_with_offloaded_attribute_should_be_stored_as_a_pointer_and_reconstituted(t *testing.T) {
+	var err error
+
+	offloadedSimpleRecordsTable := Table[simpleRecord]{
+		Name: simpleRecordsTableName,
+		Codecs: []ItemCodec{
+			Offloading{
+				Attributes: []string{"some_value"},
+				Threshold:  64,
+				Store:      NewInMemoryBlobStore(),
+			},
+		},
+	}
+
+	partitionKey := uuid.New().String()
+	record := simpleRecord{
+		PartitionKey: partitionKey,
+		SomeValue:    strings.Repeat("big value ", 100),
+	}
+
+	err = offloadedSimpleRecordsTable.Action(dynamodbClient).Persist(record)
+	assert.NoError(t, err)
+
+	getEventOutput, err := dynamodbClient.GetItem(
+		&dynamodb.GetItemInput{
+			TableName: aws.String(simpleRecordsTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"partition_key": {
+					S: aws.String(partitionKey),
+				},
+			},
+		},
+	)
+	assert.NoError(t, err)
+	assert.True(t, isOffloadedBlobPointer(getEventOutput.Item["some_value"]))
+
+	actualRecord := simpleRecord{
+		PartitionKey: partitionKey,
+	}
+	err = offloadedSimpleRecordsTable.Action(dynamodbClient).Reconstitute(&actualRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, record, actualRecord)
+}
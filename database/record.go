@@ -4,11 +4,17 @@ type Record interface {
 	ThePrimaryKey() PrimaryKey
 }
 
+// RecordWithRequiredAttributes is implemented by records that can not be decoded from items missing any of the attributes.
+type RecordWithRequiredAttributes interface {
+	RequiredAttributes() []string
+}
+
 type PrimaryKey struct {
 	PartitionKey DynamodbKey
 	SortKey      *DynamodbKey
 }
 
 type Table[R Record] struct {
-	Name string
+	Name   string
+	Codecs []ItemCodec
 }
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func ErrUnsupportedStreamAttribute(dataType events.DynamoDBDataType) error {
+	return fmt.Errorf("unsupported stream attribute of data type %d", dataType)
+}
+
+func ErrMissingStreamAttribute(attributeName string) error {
+	return fmt.Errorf("stream image has no %s attribute", attributeName)
+}
+
+type StreamChange[R Record] struct {
+	EventId        string
+	SequenceNumber string
+	EventName      events.DynamoDBOperationType
+	OldImage       *R
+	NewImage       *R
+}
+
+// StreamChange decodes the images of the stream record with the dynamodbav tags and the codecs of the table.
+func (table Table[R]) StreamChange(record events.DynamoDBEventRecord) (change StreamChange[R], err error) {
+	change = StreamChange[R]{
+		EventId:        record.EventID,
+		SequenceNumber: record.Change.SequenceNumber,
+		EventName:      events.DynamoDBOperationType(record.EventName),
+	}
+	if len(record.Change.OldImage) > 0 {
+		var oldImage R
+		oldImage, err = table.FromStreamImage(record.Change.OldImage)
+		if err != nil {
+			return
+		}
+		change.OldImage = &oldImage
+	}
+	if len(record.Change.NewImage) > 0 {
+		var newImage R
+		newImage, err = table.FromStreamImage(record.Change.NewImage)
+		if err != nil {
+			return
+		}
+		change.NewImage = &newImage
+	}
+	return
+}
+
+// FromStreamImage rejects images missing the required attributes of the record instead of leaving them zero.
+func (table Table[R]) FromStreamImage(image map[string]events.DynamoDBAttributeValue) (record R, err error) {
+	if withRequiredAttributes, ok := any(record).(RecordWithRequiredAttributes); ok {
+		for _, attributeName := range withRequiredAttributes.RequiredAttributes() {
+			attribute, ok := image[attributeName]
+			if !ok || attribute.IsNull() {
+				err = ErrMissingStreamAttribute(attributeName)
+				return
+			}
+		}
+	}
+	item, err := StreamImageToItem(image)
+	if err != nil {
+		return
+	}
+	err = table.unmarshal(item, &record)
+	return
+}
+
+// EachStreamChange hands the decoded changes to the handler one by one and stops on the first error.
+func (table Table[R]) EachStreamChange(event events.DynamoDBEvent, handle func(change StreamChange[R]) (err error)) (err error) {
+	for _, record := range event.Records {
+		var change StreamChange[R]
+		change, err = table.StreamChange(record)
+		if err != nil {
+			return
+		}
+		err = handle(change)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func StreamImageToItem(image map[string]events.DynamoDBAttributeValue) (item map[string]*dynamodb.AttributeValue, err error) {
+	item = make(map[string]*dynamodb.AttributeValue, len(image))
+	for name, attribute := range image {
+		item[name], err = streamAttributeToAttributeValue(attribute)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func streamAttributeToAttributeValue(attribute events.DynamoDBAttributeValue) (attributeValue *dynamodb.AttributeValue, err error) {
+	attributeValue = &dynamodb.AttributeValue{}
+	switch attribute.DataType() {
+	case events.DataTypeBinary:
+		attributeValue.B = attribute.Binary()
+	case events.DataTypeBoolean:
+		attributeValue.BOOL = aws.Bool(attribute.Boolean())
+	case events.DataTypeBinarySet:
+		attributeValue.BS = attribute.BinarySet()
+	case events.DataTypeList:
+		list := attribute.List()
+		attributeValue.L = make([]*dynamodb.AttributeValue, len(list))
+		for i, element := range list {
+			attributeValue.L[i], err = streamAttributeToAttributeValue(element)
+			if err != nil {
+				return
+			}
+		}
+	case events.DataTypeMap:
+		attributeValue.M, err = StreamImageToItem(attribute.Map())
+	case events.DataTypeNumber:
+		attributeValue.N = aws.String(attribute.Number())
+	case events.DataTypeNumberSet:
+		attributeValue.NS = aws.StringSlice(attribute.NumberSet())
+	case events.DataTypeNull:
+		attributeValue.NULL = aws.Bool(true)
+	case events.DataTypeString:
+		attributeValue.S = aws.String(attribute.String())
+	case events.DataTypeStringSet:
+		attributeValue.SS = aws.StringSlice(attribute.StringSet())
+	default:
+		err = ErrUnsupportedStreamAttribute(attribute.DataType())
+	}
+	return
+}
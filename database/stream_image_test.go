@@ -0,0 +1,122 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StreamImage_should_be_converted_into_an_item(t *testing.T) {
+	image := map[string]events.DynamoDBAttributeValue{
+		"string":     events.NewStringAttribute("value"),
+		"number":     events.NewNumberAttribute("42"),
+		"binary":     events.NewBinaryAttribute([]byte{1, 2}),
+		"boolean":    events.NewBooleanAttribute(true),
+		"null":       events.NewNullAttribute(),
+		"string_set": events.NewStringSetAttribute([]string{"a", "b"}),
+		"number_set": events.NewNumberSetAttribute([]string{"1", "2"}),
+		"binary_set": events.NewBinarySetAttribute([][]byte{{1}, {2}}),
+		"list": events.NewListAttribute([]events.DynamoDBAttributeValue{
+			events.NewStringAttribute("first"),
+			events.NewNumberAttribute("2"),
+		}),
+		"map": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+			"nested": events.NewStringAttribute("nested value"),
+		}),
+	}
+
+	actualItem, err := StreamImageToItem(image)
+	assert.NoError(t, err)
+
+	expectedItem := map[string]*dynamodb.AttributeValue{
+		"string":     {S: aws.String("value")},
+		"number":     {N: aws.String("42")},
+		"binary":     {B: []byte{1, 2}},
+		"boolean":    {BOOL: aws.Bool(true)},
+		"null":       {NULL: aws.Bool(true)},
+		"string_set": {SS: aws.StringSlice([]string{"a", "b"})},
+		"number_set": {NS: aws.StringSlice([]string{"1", "2"})},
+		"binary_set": {BS: [][]byte{{1}, {2}}},
+		"list": {L: []*dynamodb.AttributeValue{
+			{S: aws.String("first")},
+			{N: aws.String("2")},
+		}},
+		"map": {M: map[string]*dynamodb.AttributeValue{
+			"nested": {S: aws.String("nested value")},
+		}},
+	}
+	assert.Equal(t, expectedItem, actualItem)
+}
+
+func Test_StreamChange_should_decode_old_and_new_images_with_the_codecs_of_the_table(t *testing.T) {
+	encryption := Encryption{
+		Attributes:  []string{"some_value"},
+		KeyProvider: localKeyProvider,
+	}
+	encryptedCompositeRecordsTable := Table[compositeRecord]{
+		Name:   compositeRecordsTableName,
+		Codecs: []ItemCodec{encryption},
+	}
+
+	encryptedValue := map[string]*dynamodb.AttributeValue{"some_value": {S: aws.String("new value")}}
+	err := encryption.Encode(encryptedValue)
+	assert.NoError(t, err)
+
+	record := events.DynamoDBEventRecord{
+		EventID:   "event id",
+		EventName: "MODIFY",
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: "100",
+			OldImage: map[string]events.DynamoDBAttributeValue{
+				"partition_key": events.NewStringAttribute("partition key"),
+				"sort_key":      events.NewNumberAttribute("1"),
+				"some_value":    events.NewStringAttribute("old value"),
+			},
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"partition_key": events.NewStringAttribute("partition key"),
+				"sort_key":      events.NewNumberAttribute("1"),
+				"some_value":    events.NewStringAttribute(*encryptedValue["some_value"].S),
+			},
+		},
+	}
+
+	actualChange, err := encryptedCompositeRecordsTable.StreamChange(record)
+	assert.NoError(t, err)
+
+	expectedChange := StreamChange[compositeRecord]{
+		EventId:        "event id",
+		SequenceNumber: "100",
+		EventName:      events.DynamoDBOperationTypeModify,
+		OldImage: &compositeRecord{
+			PartitionKey: "partition key",
+			SortKey:      1,
+			SomeValue:    "old value",
+		},
+		NewImage: &compositeRecord{
+			PartitionKey: "partition key",
+			SortKey:      1,
+			SomeValue:    "new value",
+		},
+	}
+	assert.Equal(t, expectedChange, actualChange)
+}
+
+func Test_StreamChange_should_not_have_an_old_image_for_inserts(t *testing.T) {
+	record := events.DynamoDBEventRecord{
+		EventName: "INSERT",
+		Change: events.DynamoDBStreamRecord{
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"partition_key": events.NewStringAttribute("partition key"),
+			},
+		},
+	}
+
+	actualChange, err := simpleRecordsTable.StreamChange(record)
+	assert.NoError(t, err)
+	assert.Equal(t, events.DynamoDBOperationTypeInsert, actualChange.EventName)
+	assert.Nil(t, actualChange.OldImage)
+	assert.Equal(t, &simpleRecord{PartitionKey: "partition key"}, actualChange.NewImage)
+}
@@ -5,13 +5,12 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
 func (table Table[R]) TransactInsert(
 	record R,
 ) (item *dynamodb.TransactWriteItem, err error) {
-	items, err := dynamodbattribute.MarshalMap(record)
+	items, err := table.marshal(record)
 	if err != nil {
 		return
 	}
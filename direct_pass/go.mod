@@ -30,6 +30,7 @@ require (
 	github.com/gitlotto/common/env_var v0.0.0-00010101000000-000000000000
 	github.com/gitlotto/common/logging v0.0.0-00010101000000-000000000000
 	github.com/gitlotto/common/notification v0.0.0-00010101000000-000000000000
+	github.com/gitlotto/common/publisher v0.0.0-00010101000000-000000000000
 	github.com/gitlotto/common/queue v0.0.0-00010101000000-000000000000
 	github.com/gitlotto/common/workflows v0.0.0-00010101000000-000000000000
 	go.uber.org/zap v1.27.0
@@ -38,6 +39,8 @@ require (
 
 replace github.com/gitlotto/common/workflows => ../workflows
 
+replace github.com/gitlotto/common/batcher => ../batcher
+
 replace github.com/gitlotto/common/database => ../database
 
 replace github.com/gitlotto/common/env_var => ../env_var
@@ -46,5 +49,7 @@ replace github.com/gitlotto/common/logging => ../logging
 
 replace github.com/gitlotto/common/notification => ../notification
 
+replace github.com/gitlotto/common/publisher => ../publisher
+
 replace github.com/gitlotto/common/queue => ../queue
 
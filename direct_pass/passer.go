@@ -5,12 +5,11 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/notification"
+	"github.com/gitlotto/common/publisher"
 	"github.com/gitlotto/common/workflows"
 	"github.com/gitlotto/common/zulu"
 	"github.com/google/uuid"
@@ -21,6 +20,8 @@ type DirectPasser struct {
 	workflowsTableName   string
 	notificationTopicArn string
 	nextStartIn          time.Duration
+	leaseFor             time.Duration
+	publisher            publisher.Publisher
 	awsSession           *session.Session
 	logger               *zap.Logger
 }
@@ -32,7 +33,6 @@ func (passer *DirectPasser) Pass(event events.DynamoDBEvent) (err error) {
 	awsSession := passer.awsSession
 
 	dynamodbClient := dynamodb.New(awsSession)
-	sqsClient := sqs.New(awsSession)
 	postman := notification.NewPostman(awsSession, passer.notificationTopicArn)
 
 	requestId := uuid.New().String()
@@ -67,38 +67,40 @@ func (passer *DirectPasser) Pass(event events.DynamoDBEvent) (err error) {
 			return
 		}
 
-		workflowRecord, err := unmarshalWorkflow(record.Change.NewImage)
+		workflowRecord, err := workflowsTable.FromStreamImage(record.Change.NewImage)
 		if err != nil {
 			logger.Error("impossible to unmarshal workflow record", zap.Error(err))
 			return
 		}
 
 		now := time.Now()
+		delay := workflowRecord.StartAt.ToTime().Sub(now)
 
-		if workflowRecord.StartAt.ToTime().After(now) {
+		if delay > 0 && (!workflowRecord.SupportsDelay() || delay > workflows.MaxMessageDelay) {
 			logger.Info("workflow is not ready to be passed")
 			return
 		}
+		if delay < 0 {
+			delay = 0
+		}
 
 		logger = logger.With(zap.String("eventId", workflowRecord.EventId))
 		logger = logger.With(zap.String("targetQueueUrl", workflowRecord.TargetQueueUrl))
+
+		logger.Info("claiming workflow ...")
+		workflowRecord, err = workflowsTable.Claim(workflowRecord, record.EventID, now, delay+passer.leaseFor)
+		if err == workflows.ErrWorkflowIsClaimed {
+			logger.Info("workflow is claimed by another runner")
+			err = nil
+			return
+		}
+		if err != nil {
+			logger.Error("impossible to claim workflow", zap.Error(err))
+			return
+		}
+
 		logger.Info("sending event ...")
-		_, err = sqsClient.SendMessage(&sqs.SendMessageInput{
-			MessageBody:            aws.String(workflowRecord.Event),
-			QueueUrl:               aws.String(workflowRecord.TargetQueueUrl),
-			MessageGroupId:         aws.String(workflowRecord.EventMessageGroupId),
-			MessageDeduplicationId: aws.String(workflowRecord.EventMessageDeduplicationId()),
-			MessageAttributes: map[string]*sqs.MessageAttributeValue{
-				"EventId": {
-					DataType:    aws.String("String"),
-					StringValue: aws.String(workflowRecord.EventId),
-				},
-				"TargetQueueUrl": {
-					DataType:    aws.String("String"),
-					StringValue: aws.String(workflowRecord.TargetQueueUrl),
-				},
-			},
-		})
+		messageId, err := passer.publisher.Publish(workflowRecord, delay)
 
 		if err != nil {
 			logger.Error("impossible to send event", zap.Error(err))
@@ -106,8 +108,9 @@ func (passer *DirectPasser) Pass(event events.DynamoDBEvent) (err error) {
 		}
 
 		logger.Info("event sent. Postponing workflow ...")
-		nextStartAt := now.Add(passer.nextStartIn)
-		err = workflowsTable.Postpone(workflowRecord, zulu.DateTimeFromTime(nextStartAt))
+		nextStartAt := now.Add(delay + workflowRecord.NextStartIn(passer.nextStartIn))
+		attempt := workflows.NewAttempt(workflowRecord, zulu.DateTimeFromTime(now), messageId, nil)
+		err = workflowsTable.PostponeWithAttempt(workflowRecord, zulu.DateTimeFromTime(nextStartAt), attempt)
 
 		if err != nil {
 			logger.Error("impossible to postpone workflow", zap.Error(err))
@@ -15,6 +15,7 @@ import (
 
 	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/logging"
+	"github.com/gitlotto/common/publisher"
 	"github.com/gitlotto/common/queue"
 	"github.com/gitlotto/common/workflows"
 	"github.com/gitlotto/common/zulu"
@@ -37,6 +38,8 @@ var passer = DirectPasser{
 	workflowsTableName:   workflowsTableName,
 	notificationTopicArn: "arn:aws:sns:us-east-1:000000000000:direct_passer_notification-Notifications.fifo",
 	nextStartIn:          sevenHours,
+	leaseFor:             time.Minute,
+	publisher:            publisher.NewAwsPublisher(awsSession, "gitlotto.workflows"),
 	awsSession:           awsSession,
 	logger:               logger,
 }
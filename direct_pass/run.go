@@ -10,9 +10,12 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/gitlotto/common/env_var"
 	"github.com/gitlotto/common/logging"
+	"github.com/gitlotto/common/publisher"
 )
 
 const nextStartIn = time.Minute * 10
+const leaseFor = time.Minute * 5
+const defaultEventSource = "gitlotto.workflows"
 
 func Run() {
 
@@ -26,6 +29,7 @@ func Run() {
 	workflowsTableName := envVarReader.MustFind("WORKFLOWS_TABLE_NAME")
 	notificationTopicArn := envVarReader.MustFind("NOTIFICATION_TOPIC_ARN")
 	awsRegion := envVarReader.MustFind("AWS_REGION")
+	eventSource := envVarReader.FindOrDefault("EVENT_SOURCE", defaultEventSource)
 
 	awsConfig := &aws.Config{
 		Region: &awsRegion,
@@ -41,6 +45,8 @@ func Run() {
 		workflowsTableName:   workflowsTableName,
 		notificationTopicArn: notificationTopicArn,
 		nextStartIn:          nextStartIn,
+		leaseFor:             leaseFor,
+		publisher:            publisher.NewAwsPublisher(awsSession, eventSource),
 		logger:               logger,
 		awsSession:           awsSession,
 	}
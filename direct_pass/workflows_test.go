@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/workflows"
 	"github.com/gitlotto/common/zulu"
 	"github.com/google/uuid"
@@ -34,12 +35,14 @@ func Test_open_workflowRecord_should_be_reconstituted_from_the_dynamodb_event(t
 		"is_open":                events.NewStringAttribute(string(workflows.Open)),
 		"event":                  events.NewStringAttribute(event),
 		"event_message_group_id": events.NewStringAttribute(eventGroupId),
+		"queue_kind":             events.NewStringAttribute(string(workflows.FifoQueue)),
+		"workflow_state":         events.NewStringAttribute(string(workflows.StatusOpen)),
 	}
 
 	expectedWorkflow, err := workflows.NewFifoWorkflowRecord(tableName, partitionKey, nil, createdAt, startAt, targetQueueUrl, event, eventGroupId)
 	assert.NoError(t, err)
 
-	actualWorkflow, err := unmarshalWorkflow(newImage)
+	actualWorkflow, err := workflowsDynamodbTable.FromStreamImage(newImage)
 	assert.NoError(t, err)
 
 	assert.Equal(t, *expectedWorkflow, actualWorkflow)
@@ -68,15 +71,54 @@ func Test_closed_workflowRecord_should_be_reconstituted_from_the_dynamodb_event(
 		"finished_at":            events.NewStringAttribute(finishedAt.String()),
 		"event":                  events.NewStringAttribute(event),
 		"event_message_group_id": events.NewStringAttribute(eventGroupId),
+		"queue_kind":             events.NewStringAttribute(string(workflows.FifoQueue)),
+		"workflow_state":         events.NewStringAttribute(string(workflows.StatusFinished)),
 	}
 
 	expectedWorkflow, err := workflows.NewFifoWorkflowRecord(tableName, partitionKey, nil, createdAt, startAt, targetQueueUrl, event, eventGroupId)
 	expectedWorkflow.FinishedAt = &finishedAt
 	expectedWorkflow.IsOpen = nil
+	expectedWorkflow.State = workflows.StatusFinished
 	assert.NoError(t, err)
 
-	actualWorkflow, err := unmarshalWorkflow(newImage)
+	actualWorkflow, err := workflowsDynamodbTable.FromStreamImage(newImage)
 	assert.NoError(t, err)
 
 	assert.Equal(t, *expectedWorkflow, actualWorkflow)
 }
+
+func Test_workflowRecord_with_invalid_start_at_should_not_be_reconstituted_from_the_dynamodb_event(t *testing.T) {
+
+	newImage := map[string]events.DynamoDBAttributeValue{
+		"event_id":               events.NewStringAttribute(uuid.New().String()),
+		"created_at":             events.NewStringAttribute("2023-10-15T12:45:14Z"),
+		"start_at":               events.NewStringAttribute("not a date"),
+		"amount_of_starts":       events.NewNumberAttribute("0"),
+		"target_queue_url":       events.NewStringAttribute(uuid.New().String() + ".fifo"),
+		"is_open":                events.NewStringAttribute(string(workflows.Open)),
+		"event":                  events.NewStringAttribute("event"),
+		"event_message_group_id": events.NewStringAttribute(uuid.New().String()),
+	}
+
+	_, err := workflowsDynamodbTable.FromStreamImage(newImage)
+	assert.Error(t, err)
+}
+
+func Test_workflowRecord_without_required_attributes_should_not_be_reconstituted_from_the_dynamodb_event(t *testing.T) {
+
+	for _, requiredAttribute := range []string{"event_id", "target_queue_url", "start_at"} {
+		newImage := map[string]events.DynamoDBAttributeValue{
+			"event_id":         events.NewStringAttribute(uuid.New().String()),
+			"created_at":       events.NewStringAttribute("2023-10-15T12:45:14Z"),
+			"start_at":         events.NewStringAttribute("2023-10-16T12:45:14Z"),
+			"amount_of_starts": events.NewNumberAttribute("0"),
+			"target_queue_url": events.NewStringAttribute(uuid.New().String()),
+			"is_open":          events.NewStringAttribute(string(workflows.Open)),
+			"event":            events.NewStringAttribute("event"),
+		}
+		delete(newImage, requiredAttribute)
+
+		_, err := workflowsDynamodbTable.FromStreamImage(newImage)
+		assert.Equal(t, database.ErrMissingStreamAttribute(requiredAttribute), err)
+	}
+}
@@ -19,3 +19,12 @@ func (reader EnvVarReader) MustFind(name string) string {
 	}
 	return varable
 }
+
+func (reader EnvVarReader) FindOrDefault(name string, defaultValue string) string {
+	varable, vaiableExists := os.LookupEnv(name)
+	if !vaiableExists {
+		reader.Logger.Info(fmt.Sprintf("%s is missing, using the default %s", name, defaultValue))
+		return defaultValue
+	}
+	return varable
+}
@@ -0,0 +1,80 @@
+package outboxer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/gitlotto/common/notification"
+	"github.com/gitlotto/common/workflows"
+	"go.uber.org/zap"
+)
+
+// Monitor watches the backlog of the outboxer and notifies when it breaches the thresholds.
+type Monitor struct {
+	workflowsTableName     string
+	openWorkflowsIndexName string
+	openWorkflowsShards    int
+	notificationTopicArn   string
+	pageSize               int
+	staleAfter             time.Duration
+	thresholds             workflows.OpenWorkflowsThresholds
+	awsSession             *session.Session
+	logger                 *zap.Logger
+}
+
+// Check goes through the open workflows until the deadline, a zero deadline lets it go through all of them.
+func (monitor *Monitor) Check(requestId string, now time.Time, deadline time.Time) (err error) {
+
+	logger := monitor.logger
+	defer logger.Sync()
+
+	postman := notification.NewPostman(monitor.awsSession, monitor.notificationTopicArn)
+
+	logger = logger.With(zap.String("requestId", requestId))
+	logger.Info("checking open workflows ...")
+
+	openWorkflowIndex := workflows.OpenWorkflowsIndex{
+		TableName:      monitor.workflowsTableName,
+		IndexName:      monitor.openWorkflowsIndexName,
+		Shards:         monitor.openWorkflowsShards,
+		DynamodbClient: dynamodb.New(monitor.awsSession),
+	}
+
+	budget := workflows.OpenWorkflowsBudget{
+		PageSize: monitor.pageSize,
+		Deadline: deadline,
+	}
+	stats, err := openWorkflowIndex.Stats(now, monitor.staleAfter, budget)
+	if err != nil {
+		logger.Error("impossible to collect stats of open workflows", zap.Error(err))
+		postman.SendNotification(requestId, err.Error())
+		return
+	}
+
+	logger = logger.With(
+		zap.Int("amountOfOpenWorkflows", stats.AmountOfOpenWorkflows),
+		zap.Duration("lag", stats.Lag),
+		zap.Int("amountOfStaleWorkflows", stats.AmountOfStaleWorkflows),
+		zap.Any("amountOfStartsDistribution", stats.AmountOfStartsDistribution),
+		zap.Bool("isPartial", stats.IsPartial),
+	)
+	if stats.IsPartial {
+		logger.Warn("deadline passed before every open workflow was checked, the amounts are lower bounds")
+	}
+	for _, staleWorkflow := range stats.StaleWorkflows {
+		logger.Warn("stale workflow", zap.String("eventId", staleWorkflow.EventId), zap.String("targetQueueUrl", staleWorkflow.TargetQueueUrl))
+	}
+
+	breaches := stats.Breaches(monitor.thresholds)
+	if len(breaches) == 0 {
+		logger.Info("open workflows are within the thresholds")
+		return
+	}
+
+	logger.Error("open workflows breach the thresholds", zap.Strings("breaches", breaches))
+	err = postman.SendNotification(requestId, fmt.Sprintf("open workflows breach the thresholds: %s (%s)", strings.Join(breaches, "; "), stats))
+	return
+}
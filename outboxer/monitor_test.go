@@ -0,0 +1,72 @@
+package outboxer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gitlotto/common/queue"
+	"github.com/gitlotto/common/workflows"
+)
+
+var monitor = Monitor{
+	workflowsTableName:     workflowsTableName,
+	openWorkflowsIndexName: "outboxer_dynamodb-openWorkflows",
+	notificationTopicArn:   "arn:aws:sns:us-east-1:000000000000:outboxer_notification-Notifications.fifo",
+	pageSize:               2,
+	staleAfter:             time.Hour,
+	thresholds: workflows.OpenWorkflowsThresholds{
+		MaxLag: time.Hour * 2,
+	},
+	awsSession: awsSession,
+	logger:     logger,
+}
+
+func Test_Monitor_should_notify_if_open_workflows_breach_the_thresholds(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(makeFifoWorkflowRecord(queueOne, now.Add(-time.Hour*3)))
+	assert.NoError(t, err)
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(makeFifoWorkflowRecord(queueOne, now.Add(time.Hour)))
+	assert.NoError(t, err)
+
+	requestId := uuid.New().String()
+	err = monitor.Check(requestId, now, time.Time{})
+	assert.NoError(t, err)
+
+	lastNCommandsFromNotificationQueue, err := queue.GetLastNCommands(sqsClient, notificationQueueUrl, 1)
+	assert.NoError(t, err)
+
+	expectedNotification := fmt.Sprintf(
+		`{"requestId":"%s","message":"open workflows breach the thresholds: lag of 3h0m0s exceeds 2h0m0s (open=2 lag=3h0m0s stale=1 starts=[0:2])"}`,
+		requestId,
+	)
+	assert.Equal(t, expectedNotification, *lastNCommandsFromNotificationQueue[0].Body)
+}
+
+func Test_Monitor_should_not_notify_if_open_workflows_are_within_the_thresholds(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	_, err = queue.GetLastNCommands(sqsClient, notificationQueueUrl, 1)
+	assert.NoError(t, err)
+
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(makeFifoWorkflowRecord(queueOne, time.Now().Add(-time.Minute)))
+	assert.NoError(t, err)
+
+	err = monitor.Check(uuid.New().String(), time.Now(), time.Time{})
+	assert.NoError(t, err)
+
+	lastNCommandsFromNotificationQueue, err := queue.GetLastNCommands(sqsClient, notificationQueueUrl, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, lastNCommandsFromNotificationQueue)
+}
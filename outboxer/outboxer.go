@@ -4,12 +4,11 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/notification"
+	"github.com/gitlotto/common/publisher"
 	"github.com/gitlotto/common/workflows"
 	"github.com/gitlotto/common/zulu"
 	"go.uber.org/zap"
@@ -18,21 +17,26 @@ import (
 type Outboxer struct {
 	workflowsTableName        string
 	openWorkflowsIndexName    string
+	openWorkflowsShards       int
 	notificationTopicArn      string
 	amountOfWorkflowsToOutbox int
+	pageSize                  int
 	nextStartIn               time.Duration
+	leaseFor                  time.Duration
+	publisher                 publisher.Publisher
 	awsSession                *session.Session
 	logger                    *zap.Logger
 }
 
-func (outboxer *Outboxer) Outbox(requestId string) (err error) {
+// Outbox sends the events of the open workflows until there are no more of them, amountOfWorkflowsToOutbox
+// workflows are sent or the deadline passes. A zero deadline means no deadline.
+func (outboxer *Outboxer) Outbox(requestId string, deadline time.Time) (err error) {
 
 	logger := outboxer.logger
 	defer logger.Sync()
 	awsSession := outboxer.awsSession
 
 	dynamodbClient := dynamodb.New(awsSession)
-	sqsClient := sqs.New(awsSession)
 	postman := notification.NewPostman(awsSession, outboxer.notificationTopicArn)
 
 	defer func() {
@@ -52,20 +56,10 @@ func (outboxer *Outboxer) Outbox(requestId string) (err error) {
 	openWorkflowIndex := workflows.OpenWorkflowsIndex{
 		TableName:      outboxer.workflowsTableName,
 		IndexName:      outboxer.openWorkflowsIndexName,
+		Shards:         outboxer.openWorkflowsShards,
 		DynamodbClient: dynamodbClient,
 	}
 
-	now := time.Now()
-
-	workflowRecords, err := openWorkflowIndex.OpenWorkflows(outboxer.amountOfWorkflowsToOutbox, zulu.DateTimeFromTime(now))
-
-	logger = logger.With(zap.Int("amountOfWorkflows", len(workflowRecords)))
-	logger.Info("fetched open workflows")
-
-	if err != nil {
-		logger.Error("impossible to fetch open workflows", zap.Error(err))
-	}
-
 	var errorsFromEventSending []error
 
 	defer func() {
@@ -74,26 +68,47 @@ func (outboxer *Outboxer) Outbox(requestId string) (err error) {
 		}
 	}()
 
-	for _, workflowRecord := range workflowRecords {
-		logger = logger.With(zap.String("eventId", workflowRecord.EventId))
+	budget := workflows.OpenWorkflowsBudget{
+		PageSize:     outboxer.pageSize,
+		MaxWorkflows: outboxer.amountOfWorkflowsToOutbox,
+		Deadline:     deadline,
+	}
+
+	outboxSingle := func(workflowRecord workflows.WorkflowRecord) (err error) {
+		logger := logger.With(zap.String("eventId", workflowRecord.EventId))
 		logger = logger.With(zap.String("targetQueueUrl", workflowRecord.TargetQueueUrl))
+
+		logger.Info("claiming workflow ...")
+		workflowRecord, err = workflowsTable.Claim(workflowRecord, requestId, time.Now(), outboxer.leaseFor)
+		if err == workflows.ErrWorkflowIsClaimed {
+			logger.Info("workflow is claimed by another runner")
+			return nil
+		}
+		if err != nil {
+			logger.Error("impossible to claim workflow", zap.Error(err))
+			return
+		}
+
+		if workflowRecord.AttemptsExhausted() {
+			logger.Info("workflow exhausted its attempts. Failing workflow ...")
+			reason := fmt.Sprintf("exhausted %d attempts", workflowRecord.AmountOfStarts)
+			err = workflowsTable.Fail(workflowRecord, zulu.DateTimeFromTime(time.Now()), reason)
+			if err == workflows.ErrWorkflowHadBeenFinished {
+				logger.Info("workflow had been finished before failing it")
+				return nil
+			}
+			if err != nil {
+				logger.Error("impossible to fail workflow", zap.Error(err))
+				return
+			}
+			logger.Info("workflow failed")
+			postman.SendNotification(requestId, fmt.Sprintf("workflow %s to %s failed: %s", workflowRecord.EventId, workflowRecord.TargetQueueUrl, reason))
+			return
+		}
+
 		logger.Info("sending event ...")
-		_, errFromEventSending := sqsClient.SendMessage(&sqs.SendMessageInput{
-			MessageBody:            aws.String(workflowRecord.Event),
-			QueueUrl:               aws.String(workflowRecord.TargetQueueUrl),
-			MessageGroupId:         aws.String(workflowRecord.EventMessageGroupId),
-			MessageDeduplicationId: aws.String(workflowRecord.EventMessageDeduplicationId()),
-			MessageAttributes: map[string]*sqs.MessageAttributeValue{
-				"EventId": {
-					DataType:    aws.String("String"),
-					StringValue: aws.String(workflowRecord.EventId),
-				},
-				"TargetQueueUrl": {
-					DataType:    aws.String("String"),
-					StringValue: aws.String(workflowRecord.TargetQueueUrl),
-				},
-			},
-		})
+		sentAt := time.Now()
+		messageId, errFromEventSending := outboxer.publisher.Publish(workflowRecord, 0)
 
 		if errFromEventSending != nil {
 			logger.Error("impossible to send event", zap.Error(errFromEventSending))
@@ -102,15 +117,29 @@ func (outboxer *Outboxer) Outbox(requestId string) (err error) {
 
 		logger.Info("event sent. Postponing workflow ...")
 		now := time.Now()
-		nextStartAt := now.Add(outboxer.nextStartIn)
-		err = workflowsTable.Postpone(workflowRecord, zulu.DateTimeFromTime(nextStartAt))
-
+		nextStartAt := now.Add(workflowRecord.NextStartIn(outboxer.nextStartIn))
+		attempt := workflows.NewAttempt(workflowRecord, zulu.DateTimeFromTime(sentAt), messageId, errFromEventSending)
+		err = workflowsTable.PostponeWithAttempt(workflowRecord, zulu.DateTimeFromTime(nextStartAt), attempt)
+		if err == workflows.ErrWorkflowHadBeenFinished {
+			// the consumer or direct_pass may finish the workflow as soon as the event is sent
+			logger.Info("workflow had been finished before postponing it")
+			return nil
+		}
 		if err != nil {
 			logger.Error("impossible to postpone workflow", zap.Error(err))
 			return
 		}
 		logger.Info("workflow postponed")
+		return
+	}
+
+	amountOfWorkflows, err := openWorkflowIndex.EachOpenWorkflow(zulu.DateTimeFromTime(time.Now()), budget, outboxSingle)
 
+	logger = logger.With(zap.Int("amountOfWorkflows", amountOfWorkflows))
+
+	if err != nil {
+		logger.Error("impossible to outbox open workflows", zap.Error(err))
+		return
 	}
 
 	logger.Info("workflows outboxed")
@@ -14,6 +14,7 @@ import (
 
 	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/logging"
+	"github.com/gitlotto/common/publisher"
 	"github.com/gitlotto/common/queue"
 	"github.com/gitlotto/common/workflows"
 	"github.com/gitlotto/common/zulu"
@@ -37,7 +38,10 @@ var outboxer = Outboxer{
 	openWorkflowsIndexName:    "outboxer_dynamodb-openWorkflows",
 	notificationTopicArn:      "arn:aws:sns:us-east-1:000000000000:outboxer_notification-Notifications.fifo",
 	amountOfWorkflowsToOutbox: 3,
+	pageSize:                  2,
 	nextStartIn:               sevenHours,
+	leaseFor:                  time.Minute,
+	publisher:                 publisher.NewAwsPublisher(awsSession, "gitlotto.workflows"),
 	awsSession:                awsSession,
 	logger:                    logger,
 }
@@ -49,6 +53,7 @@ var notificationQueueUrl = "http://localhost:4566/000000000000/outboxer_notifica
 
 var queueOne = "http://localhost:4566/000000000000/outboxer_random_queues-one.fifo"
 var queueTwo = "http://localhost:4566/000000000000/outboxer_random_queues-two.fifo"
+var standardQueue = "http://localhost:4566/000000000000/outboxer_random_queues-standard"
 
 var workflowsDynamodbTable = database.Table[workflows.WorkflowRecord]{
 	Name: workflowsTableName,
@@ -89,7 +94,7 @@ func Test_Workflow_Outboxer_should_pick_the_oldest_open_workflows_and_issue_even
 	assert.NoError(t, err)
 
 	requestId := uuid.New().String()
-	err = outboxer.Outbox(requestId)
+	err = outboxer.Outbox(requestId, time.Time{})
 	assert.NoError(t, err)
 
 	stratOfChecking := time.Now()
@@ -194,7 +199,7 @@ func Test_Workflow_Outboxer_should_notify_if_it_fails_to_publish_an_event(t *tes
 	assert.NoError(t, err)
 
 	requestId := uuid.New().String()
-	err = outboxer.Outbox(requestId)
+	err = outboxer.Outbox(requestId, time.Time{})
 	assert.NoError(t, err)
 
 	stratOfChecking := time.Now()
@@ -244,6 +249,187 @@ func Test_Workflow_Outboxer_should_notify_if_it_fails_to_publish_an_event(t *tes
 	assert.Equal(t, expectedNotification, *actualNotification)
 }
 
+func Test_Workflow_Outboxer_should_back_off_and_fail_workflows_by_their_retry_policy(t *testing.T) {
+	var err error
+
+	startOfTesting := time.Now()
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	retryPolicy := workflows.RetryPolicy{
+		InitialDelay: time.Minute,
+		Multiplier:   2,
+		MaxAttempts:  3,
+	}
+
+	retriedWorkflow := makeFifoWorkflowRecord(queueOne, startOfTesting.Add(-time.Hour*2))
+	workflows.WithRetryPolicy(retryPolicy)(&retriedWorkflow)
+	retriedWorkflow.AmountOfStarts = 2
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(retriedWorkflow)
+	assert.NoError(t, err)
+
+	exhaustedWorkflow := makeFifoWorkflowRecord(queueOne, startOfTesting.Add(-time.Hour))
+	workflows.WithRetryPolicy(retryPolicy)(&exhaustedWorkflow)
+	exhaustedWorkflow.AmountOfStarts = 3
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(exhaustedWorkflow)
+	assert.NoError(t, err)
+
+	requestId := uuid.New().String()
+	err = outboxer.Outbox(requestId, time.Time{})
+	assert.NoError(t, err)
+
+	stratOfChecking := time.Now()
+
+	actualRetriedWorkflow := workflows.WorkflowRecord{
+		EventId:        retriedWorkflow.EventId,
+		TargetQueueUrl: queueOne,
+	}
+	err = workflowsDynamodbTable.Action(dynamodbClient).Reconstitute(&actualRetriedWorkflow)
+	assert.NoError(t, err)
+	assert.WithinRange(t, actualRetriedWorkflow.StartAt.ToTime(), startOfTesting.Add(time.Minute*4-time.Second), stratOfChecking.Add(time.Minute*4+time.Second))
+	assert.Equal(t, 3, actualRetriedWorkflow.AmountOfStarts)
+	assert.NotNil(t, actualRetriedWorkflow.IsOpen)
+
+	actualExhaustedWorkflow := workflows.WorkflowRecord{
+		EventId:        exhaustedWorkflow.EventId,
+		TargetQueueUrl: queueOne,
+	}
+	err = workflowsDynamodbTable.Action(dynamodbClient).Reconstitute(&actualExhaustedWorkflow)
+	assert.NoError(t, err)
+	assert.Nil(t, actualExhaustedWorkflow.IsOpen)
+	assert.NotNil(t, actualExhaustedWorkflow.FailedAt)
+	assert.Equal(t, "exhausted 3 attempts", *actualExhaustedWorkflow.FailureReason)
+	assert.Equal(t, 3, actualExhaustedWorkflow.AmountOfStarts)
+
+	lastNCommandsFromNotificationQueue, err := queue.GetLastNCommands(sqsClient, notificationQueueUrl, 1)
+	assert.NoError(t, err)
+
+	expectedNotification := fmt.Sprintf(
+		`{"requestId":"%s","message":"workflow %s to %s failed: exhausted 3 attempts"}`,
+		requestId, exhaustedWorkflow.EventId, queueOne,
+	)
+	assert.Equal(t, expectedNotification, *lastNCommandsFromNotificationQueue[0].Body)
+}
+
+func Test_Workflow_Outboxer_should_issue_events_into_standard_queues(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.September, 16, 12, 45, 14, 0, time.UTC))
+	startAt := zulu.DateTimeFromTime(time.Now().Add(-time.Hour))
+	standardWorkflow, err := workflows.NewStandardWorkflowRecord(uuid.New().String(), uuid.New().String(), nil, createdAt, startAt, standardQueue, uuid.New().String())
+	assert.NoError(t, err)
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(*standardWorkflow)
+	assert.NoError(t, err)
+
+	err = outboxer.Outbox(uuid.New().String(), time.Time{})
+	assert.NoError(t, err)
+
+	lastNCommandsFromStandardQueue, err := queue.GetLastNCommands(sqsClient, standardQueue, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, standardWorkflow.Event, *lastNCommandsFromStandardQueue[0].Body)
+
+	actualStandardWorkflow := workflows.WorkflowRecord{
+		EventId:        standardWorkflow.EventId,
+		TargetQueueUrl: standardQueue,
+	}
+	err = workflowsDynamodbTable.Action(dynamodbClient).Reconstitute(&actualStandardWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, actualStandardWorkflow.AmountOfStarts)
+	assert.Equal(t, workflows.StandardQueue, actualStandardWorkflow.QueueKind)
+}
+
+func Test_Workflow_Outboxer_should_issue_every_attempt_of_workflows_deduplicated_by_attempt(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	retriedWorkflow := makeFifoWorkflowRecord(queueTwo, time.Now().Add(-time.Hour))
+	workflows.WithDeduplication(workflows.DeduplicateByAttempt)(&retriedWorkflow)
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(retriedWorkflow)
+	assert.NoError(t, err)
+
+	err = outboxer.Outbox(uuid.New().String(), time.Time{})
+	assert.NoError(t, err)
+
+	actualRetriedWorkflow := workflows.WorkflowRecord{
+		EventId:        retriedWorkflow.EventId,
+		TargetQueueUrl: queueTwo,
+	}
+	err = workflowsDynamodbTable.Action(dynamodbClient).Reconstitute(&actualRetriedWorkflow)
+	assert.NoError(t, err)
+	actualRetriedWorkflow.StartAt = zulu.DateTimeFromTime(time.Now().Add(-time.Hour))
+	err = workflowsDynamodbTable.Action(dynamodbClient).Persist(actualRetriedWorkflow)
+	assert.NoError(t, err)
+
+	err = outboxer.Outbox(uuid.New().String(), time.Time{})
+	assert.NoError(t, err)
+
+	lastNCommandsFromQueueTwo, err := queue.GetLastNCommands(sqsClient, queueTwo, 2)
+	assert.NoError(t, err)
+	assert.Len(t, lastNCommandsFromQueueTwo, 2)
+	for _, command := range lastNCommandsFromQueueTwo {
+		assert.Equal(t, retriedWorkflow.Event, *command.Body)
+	}
+}
+
+// finishingPublisher finishes the workflow as soon as its event is sent, like a fast consumer would.
+type finishingPublisher struct {
+	publisher.Recorder
+	workflowsTable workflows.WorkflowRecordTable
+}
+
+func (publisher *finishingPublisher) Publish(workflowRecord workflows.WorkflowRecord, delay time.Duration) (messageId *string, err error) {
+	messageId, err = publisher.Recorder.Publish(workflowRecord, delay)
+	if err != nil {
+		return
+	}
+	err = publisher.workflowsTable.Close(workflowRecord.EventId, workflowRecord.TargetQueueUrl, zulu.DateTimeFromTime(time.Now()))
+	return
+}
+
+func Test_Workflow_Outboxer_should_go_on_if_a_workflow_is_finished_right_after_its_event_is_sent(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	firstWorkflow := makeFifoWorkflowRecord(queueOne, now.Add(-time.Hour*2))
+	secondWorkflow := makeFifoWorkflowRecord(queueTwo, now.Add(-time.Hour))
+	for _, workflow := range []workflows.WorkflowRecord{firstWorkflow, secondWorkflow} {
+		err = workflowsDynamodbTable.Action(dynamodbClient).Persist(workflow)
+		assert.NoError(t, err)
+	}
+
+	finishing := &finishingPublisher{
+		workflowsTable: workflows.WorkflowRecordTable{
+			Table:          workflowsDynamodbTable,
+			DynamodbClient: dynamodbClient,
+		},
+	}
+	racingOutboxer := outboxer
+	racingOutboxer.publisher = finishing
+
+	err = racingOutboxer.Outbox(uuid.New().String(), time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, finishing.Publications(), 2)
+
+	for _, workflow := range []workflows.WorkflowRecord{firstWorkflow, secondWorkflow} {
+		actualWorkflow := workflows.WorkflowRecord{
+			EventId:        workflow.EventId,
+			TargetQueueUrl: workflow.TargetQueueUrl,
+		}
+		err = workflowsDynamodbTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+		assert.NoError(t, err)
+		assert.Equal(t, workflows.StatusFinished, actualWorkflow.Status())
+	}
+}
+
 func makeSimpleWorkflowRecord(targetQueueUrl string, startAt time.Time) workflows.WorkflowRecord {
 	tableName := uuid.New().String()
 	partitionKey := uuid.New().String()
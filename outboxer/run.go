@@ -1,6 +1,8 @@
 package outboxer
 
 import (
+	"context"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -10,10 +12,16 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/gitlotto/common/env_var"
 	"github.com/gitlotto/common/logging"
+	"github.com/gitlotto/common/publisher"
+	"github.com/gitlotto/common/workflows"
 )
 
-const amountOfWorkflowsToOutbox = 100
+const amountOfWorkflowsToOutbox = 1000
+const pageSize = 100
+const deadlineMargin = time.Second * 10
+const leaseFor = time.Minute * 5
 const nextStartIn = time.Minute * 10
+const defaultEventSource = "gitlotto.workflows"
 
 func Run() {
 
@@ -29,6 +37,9 @@ func Run() {
 	notificationTopicArn := envVarReader.MustFind("NOTIFICATION_TOPIC_ARN")
 	awsRegion := envVarReader.MustFind("AWS_REGION")
 
+	openWorkflowsShards := mustParseInt(envVarReader, "OPEN_WORKFLOWS_SHARDS", "1")
+	eventSource := envVarReader.FindOrDefault("EVENT_SOURCE", defaultEventSource)
+
 	awsConfig := &aws.Config{
 		Region: &awsRegion,
 	}
@@ -42,17 +53,101 @@ func Run() {
 	outboxer := Outboxer{
 		workflowsTableName:        workflowsTableName,
 		openWorkflowsIndexName:    openWorkflowsIndexName,
+		openWorkflowsShards:       openWorkflowsShards,
 		notificationTopicArn:      notificationTopicArn,
 		amountOfWorkflowsToOutbox: amountOfWorkflowsToOutbox,
+		pageSize:                  pageSize,
 		nextStartIn:               nextStartIn,
+		leaseFor:                  leaseFor,
+		publisher:                 publisher.NewAwsPublisher(awsSession, eventSource),
 		logger:                    logger,
 		awsSession:                awsSession,
 	}
 
-	handler := func(event events.CloudWatchEvent) error {
-		return outboxer.Outbox(event.ID)
+	handler := func(ctx context.Context, event events.CloudWatchEvent) error {
+		var deadline time.Time
+		if lambdaDeadline, ok := ctx.Deadline(); ok {
+			deadline = lambdaDeadline.Add(-deadlineMargin)
+		}
+		return outboxer.Outbox(event.ID, deadline)
+	}
+
+	lambda.Start(handler)
+
+}
+
+// RunMonitor is meant to be scheduled next to the outboxer. A zero threshold is not checked.
+func RunMonitor() {
+
+	logger := logging.MustCreateZuluTimeLogger()
+	defer logger.Sync()
+
+	envVarReader := env_var.EnvVarReader{
+		Logger: logger,
+	}
+
+	workflowsTableName := envVarReader.MustFind("WORKFLOWS_TABLE_NAME")
+	openWorkflowsIndexName := envVarReader.MustFind("OPEN_WORKFLOWS_INDEX_NAME")
+	notificationTopicArn := envVarReader.MustFind("NOTIFICATION_TOPIC_ARN")
+	awsRegion := envVarReader.MustFind("AWS_REGION")
+
+	openWorkflowsShards := mustParseInt(envVarReader, "OPEN_WORKFLOWS_SHARDS", "1")
+	staleAfter := mustParseDuration(envVarReader, "STALE_AFTER", "1h")
+	thresholds := workflows.OpenWorkflowsThresholds{
+		MaxOpenWorkflows:  mustParseInt(envVarReader, "MAX_OPEN_WORKFLOWS", "0"),
+		MaxLag:            mustParseDuration(envVarReader, "MAX_LAG", "0"),
+		MaxStaleWorkflows: mustParseInt(envVarReader, "MAX_STALE_WORKFLOWS", "0"),
+		MaxAmountOfStarts: mustParseInt(envVarReader, "MAX_AMOUNT_OF_STARTS", "0"),
+	}
+
+	awsConfig := &aws.Config{
+		Region: &awsRegion,
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		logger.Error("impossible to create an AWS session!")
+		panic(err)
+	}
+
+	monitor := Monitor{
+		workflowsTableName:     workflowsTableName,
+		openWorkflowsIndexName: openWorkflowsIndexName,
+		openWorkflowsShards:    openWorkflowsShards,
+		notificationTopicArn:   notificationTopicArn,
+		pageSize:               pageSize,
+		staleAfter:             staleAfter,
+		thresholds:             thresholds,
+		logger:                 logger,
+		awsSession:             awsSession,
+	}
+
+	handler := func(ctx context.Context, event events.CloudWatchEvent) error {
+		var deadline time.Time
+		if lambdaDeadline, ok := ctx.Deadline(); ok {
+			deadline = lambdaDeadline.Add(-deadlineMargin)
+		}
+		return monitor.Check(event.ID, time.Now(), deadline)
 	}
 
 	lambda.Start(handler)
 
 }
+
+func mustParseInt(envVarReader env_var.EnvVarReader, name string, defaultValue string) int {
+	value, err := strconv.Atoi(envVarReader.FindOrDefault(name, defaultValue))
+	if err != nil {
+		envVarReader.Logger.Error(name + " is not a number!")
+		panic(err)
+	}
+	return value
+}
+
+func mustParseDuration(envVarReader env_var.EnvVarReader, name string, defaultValue string) time.Duration {
+	value, err := time.ParseDuration(envVarReader.FindOrDefault(name, defaultValue))
+	if err != nil {
+		envVarReader.Logger.Error(name + " is not a duration!")
+		panic(err)
+	}
+	return value
+}
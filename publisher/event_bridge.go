@@ -0,0 +1,71 @@
+package publisher
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/gitlotto/common/workflows"
+)
+
+func ErrEventNotPutOnBus(busName string, errorCode string, errorMessage string) error {
+	return fmt.Errorf("event is not put on the bus %s: %s %s", busName, errorCode, errorMessage)
+}
+
+// EventBridgePublisher puts the event on the bus whose name or arn is the TargetQueueUrl of the workflow.
+// The event becomes the detail, so it has to be a JSON object.
+type EventBridgePublisher struct {
+	eventBridgeClient *eventbridge.EventBridge
+	source            string
+}
+
+func NewEventBridgePublisher(awsSession *session.Session, source string) EventBridgePublisher {
+	return EventBridgePublisher{
+		eventBridgeClient: eventbridge.New(awsSession),
+		source:            source,
+	}
+}
+
+func (publisher EventBridgePublisher) Publish(workflowRecord workflows.WorkflowRecord, delay time.Duration) (messageId *string, err error) {
+	output, err := publisher.eventBridgeClient.PutEvents(putEventsInput(workflowRecord, publisher.source))
+	if err != nil {
+		return
+	}
+	// PutEvents succeeds as a call even if the entry is rejected
+	if len(output.Entries) == 0 || aws.Int64Value(output.FailedEntryCount) > 0 {
+		var errorCode, errorMessage string
+		if len(output.Entries) > 0 {
+			errorCode = aws.StringValue(output.Entries[0].ErrorCode)
+			errorMessage = aws.StringValue(output.Entries[0].ErrorMessage)
+		}
+		err = ErrEventNotPutOnBus(workflowRecord.TargetQueueUrl, errorCode, errorMessage)
+		return
+	}
+	messageId = output.Entries[0].EventId
+	return
+}
+
+// putEventsInput lists the message attributes of the workflow as the resources of the event in the name=value form,
+// so rules can match single workflows. The event is validated to be a JSON object when the workflow is created.
+func putEventsInput(workflowRecord workflows.WorkflowRecord, source string) *eventbridge.PutEventsInput {
+	attributes := workflowRecord.MessageAttributes()
+	resources := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		resources = append(resources, name+"="+value)
+	}
+	sort.Strings(resources)
+	return &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(workflowRecord.TargetQueueUrl),
+				Source:       aws.String(source),
+				DetailType:   aws.String("WorkflowEvent"),
+				Detail:       aws.String(workflowRecord.Event),
+				Resources:    aws.StringSlice(resources),
+			},
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package publisher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/gitlotto/common/workflows"
+)
+
+func ErrNoPublisherForTarget(targetType workflows.TargetType) error {
+	return fmt.Errorf("there is no publisher for the target type %s", targetType)
+}
+
+// Publisher delivers the event of the workflow to its target. The delay is only applied by targets that support it.
+type Publisher interface {
+	Publish(workflowRecord workflows.WorkflowRecord, delay time.Duration) (messageId *string, err error)
+}
+
+// TargetPublisher dispatches every workflow to the publisher of its target type.
+type TargetPublisher struct {
+	publishers map[workflows.TargetType]Publisher
+}
+
+func NewTargetPublisher(publishers map[workflows.TargetType]Publisher) TargetPublisher {
+	return TargetPublisher{
+		publishers: publishers,
+	}
+}
+
+// NewAwsPublisher publishes to SQS, SNS and EventBridge. The source is the source of the EventBridge events.
+func NewAwsPublisher(awsSession *session.Session, source string) TargetPublisher {
+	return NewTargetPublisher(map[workflows.TargetType]Publisher{
+		workflows.SqsTarget:         NewSqsPublisher(awsSession),
+		workflows.SnsTarget:         NewSnsPublisher(awsSession),
+		workflows.EventBridgeTarget: NewEventBridgePublisher(awsSession, source),
+	})
+}
+
+func (publisher TargetPublisher) Publish(workflowRecord workflows.WorkflowRecord, delay time.Duration) (messageId *string, err error) {
+	targetPublisher, ok := publisher.publishers[workflowRecord.Target()]
+	if !ok {
+		err = ErrNoPublisherForTarget(workflowRecord.Target())
+		return
+	}
+	return targetPublisher.Publish(workflowRecord, delay)
+}
@@ -0,0 +1,196 @@
+package publisher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/gitlotto/common/workflows"
+	"github.com/gitlotto/common/zulu"
+	"github.com/stretchr/testify/assert"
+)
+
+var createdAt = zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+
+func makeWorkflowRecord(targetUrl string, targetType workflows.TargetType) workflows.WorkflowRecord {
+	workflowRecord, err := workflows.NewStandardWorkflowRecord("table", "partition", nil, createdAt, createdAt, targetUrl, `{"ticket":1}`, workflows.WithTargetType(targetType))
+	if err != nil {
+		panic(err)
+	}
+	return *workflowRecord
+}
+
+func Test_TargetPublisher_should_dispatch_by_the_target_type(t *testing.T) {
+	sqsRecorder := &Recorder{}
+	snsRecorder := &Recorder{}
+	publisher := NewTargetPublisher(map[workflows.TargetType]Publisher{
+		workflows.SqsTarget: sqsRecorder,
+		workflows.SnsTarget: snsRecorder,
+	})
+
+	legacyWorkflow := makeWorkflowRecord("queue", "")
+	messageId, err := publisher.Publish(legacyWorkflow, time.Minute)
+	assert.NoError(t, err)
+
+	snsWorkflow := makeWorkflowRecord("arn:aws:sns:us-east-1:000000000000:topic", workflows.SnsTarget)
+	_, err = publisher.Publish(snsWorkflow, 0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Publication{{WorkflowRecord: legacyWorkflow, Delay: time.Minute, MessageId: *messageId}}, sqsRecorder.Publications())
+	assert.Len(t, snsRecorder.Publications(), 1)
+	assert.Equal(t, snsWorkflow, snsRecorder.Publications()[0].WorkflowRecord)
+
+	_, err = publisher.Publish(makeWorkflowRecord("bus", workflows.EventBridgeTarget), 0)
+	assert.Equal(t, ErrNoPublisherForTarget(workflows.EventBridgeTarget), err)
+}
+
+func Test_Recorder_should_fail_every_publication_if_told_so(t *testing.T) {
+	errOfPublishing := errors.New("topic does not exist")
+	recorder := &Recorder{Err: errOfPublishing}
+
+	messageId, err := recorder.Publish(makeWorkflowRecord("queue", workflows.SqsTarget), 0)
+	assert.Nil(t, messageId)
+	assert.Equal(t, errOfPublishing, err)
+	assert.Empty(t, recorder.Publications())
+}
+
+func Test_SqsPublisher_should_send_standard_messages_with_a_delay(t *testing.T) {
+	workflowRecord := makeWorkflowRecord("queue", workflows.SqsTarget)
+
+	expectedInput := &sqs.SendMessageInput{
+		MessageBody: aws.String(`{"ticket":1}`),
+		QueueUrl:    aws.String("queue"),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"EventId": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("table#partition"),
+			},
+			"TargetQueueUrl": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("queue"),
+			},
+			"Occurrence": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("0"),
+			},
+		},
+		DelaySeconds: aws.Int64(120),
+	}
+	input, err := sendMessageInput(workflowRecord, time.Minute*2)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedInput, input)
+
+	input, err = sendMessageInput(workflowRecord, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(900), *input.DelaySeconds)
+
+	input, err = sendMessageInput(workflowRecord, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, input.DelaySeconds)
+}
+
+func Test_SqsPublisher_should_send_fifo_messages_without_a_delay(t *testing.T) {
+	workflowRecord, err := workflows.NewFifoWorkflowRecord("table", "partition", nil, createdAt, createdAt, "queue.fifo", `{"ticket":1}`, "group")
+	assert.NoError(t, err)
+
+	deduplicationId, err := workflowRecord.EventMessageDeduplicationId()
+	assert.NoError(t, err)
+
+	input, err := sendMessageInput(*workflowRecord, time.Minute*2)
+	assert.NoError(t, err)
+	assert.Equal(t, aws.String("group"), input.MessageGroupId)
+	assert.Equal(t, aws.String(deduplicationId), input.MessageDeduplicationId)
+	assert.Nil(t, input.DelaySeconds)
+}
+
+func Test_SqsPublisher_should_not_send_fifo_messages_with_an_unregistered_deduplication(t *testing.T) {
+	workflowRecord, err := workflows.NewFifoWorkflowRecord("table", "partition", nil, createdAt, createdAt, "queue.fifo", `{"ticket":1}`, "group")
+	assert.NoError(t, err)
+	workflows.WithDeduplication("CUSTOM#unknown")(workflowRecord)
+
+	_, err = sendMessageInput(*workflowRecord, 0)
+	assert.Equal(t, workflows.ErrUnregisteredDeduplication("CUSTOM#unknown"), err)
+
+	_, err = publishInput(*workflowRecord)
+	assert.Equal(t, workflows.ErrUnregisteredDeduplication("CUSTOM#unknown"), err)
+}
+
+func Test_Recorder_should_let_the_error_be_changed_while_publishing(t *testing.T) {
+	recorder := &Recorder{}
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			recorder.Publish(makeWorkflowRecord("queue", workflows.SqsTarget), 0)
+		}
+		done <- true
+	}()
+	recorder.SetErr(errors.New("queue does not exist"))
+	<-done
+
+	_, err := recorder.Publish(makeWorkflowRecord("queue", workflows.SqsTarget), 0)
+	assert.EqualError(t, err, "queue does not exist")
+}
+
+func Test_SnsPublisher_should_publish_with_the_attributes_of_the_workflow(t *testing.T) {
+	topicArn := "arn:aws:sns:us-east-1:000000000000:tickets.fifo"
+	workflowRecord, err := workflows.NewFifoWorkflowRecord("table", "partition", nil, createdAt, createdAt, topicArn, `{"ticket":1}`, "group", workflows.WithTargetType(workflows.SnsTarget))
+	assert.NoError(t, err)
+	deduplicationId, err := workflowRecord.EventMessageDeduplicationId()
+	assert.NoError(t, err)
+
+	expectedInput := &sns.PublishInput{
+		Message:  aws.String(`{"ticket":1}`),
+		TopicArn: aws.String(topicArn),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"EventId": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("table#partition"),
+			},
+			"TargetQueueUrl": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(topicArn),
+			},
+			"Occurrence": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("0"),
+			},
+		},
+		MessageGroupId:         aws.String("group"),
+		MessageDeduplicationId: aws.String(deduplicationId),
+	}
+	input, err := publishInput(*workflowRecord)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedInput, input)
+
+	standardInput, err := publishInput(makeWorkflowRecord("arn:aws:sns:us-east-1:000000000000:tickets", workflows.SnsTarget))
+	assert.NoError(t, err)
+	assert.Nil(t, standardInput.MessageGroupId)
+	assert.Nil(t, standardInput.MessageDeduplicationId)
+
+	legacyWorkflow := *workflowRecord
+	legacyWorkflow.QueueKind = ""
+	legacyInput, err := publishInput(legacyWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, aws.String("group"), legacyInput.MessageGroupId)
+}
+
+func Test_EventBridgePublisher_should_put_the_event_as_the_detail(t *testing.T) {
+	workflowRecord := makeWorkflowRecord("lottery", workflows.EventBridgeTarget)
+
+	expectedInput := &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String("lottery"),
+				Source:       aws.String("gitlotto.workflows"),
+				DetailType:   aws.String("WorkflowEvent"),
+				Detail:       aws.String(`{"ticket":1}`),
+				Resources:    aws.StringSlice([]string{"EventId=table#partition", "Occurrence=0", "TargetQueueUrl=lottery"}),
+			},
+		},
+	}
+	assert.Equal(t, expectedInput, putEventsInput(workflowRecord, "gitlotto.workflows"))
+}
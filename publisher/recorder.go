@@ -0,0 +1,51 @@
+package publisher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gitlotto/common/workflows"
+	"github.com/google/uuid"
+)
+
+type Publication struct {
+	WorkflowRecord workflows.WorkflowRecord
+	Delay          time.Duration
+	MessageId      string
+}
+
+// Recorder keeps the publications in memory instead of delivering them. It is meant for tests,
+// Err makes every publication fail. Change Err with SetErr while publications may be running.
+type Recorder struct {
+	Err          error
+	mutex        sync.Mutex
+	publications []Publication
+}
+
+func (recorder *Recorder) Publish(workflowRecord workflows.WorkflowRecord, delay time.Duration) (messageId *string, err error) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if recorder.Err != nil {
+		return nil, recorder.Err
+	}
+	publication := Publication{
+		WorkflowRecord: workflowRecord,
+		Delay:          delay,
+		MessageId:      uuid.New().String(),
+	}
+	recorder.publications = append(recorder.publications, publication)
+	return aws.String(publication.MessageId), nil
+}
+
+func (recorder *Recorder) SetErr(err error) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.Err = err
+}
+
+func (recorder *Recorder) Publications() []Publication {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return append([]Publication{}, recorder.publications...)
+}
@@ -0,0 +1,59 @@
+package publisher
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/gitlotto/common/workflows"
+)
+
+// SnsPublisher publishes the event to the topic whose arn is the TargetQueueUrl of the workflow.
+type SnsPublisher struct {
+	snsClient *sns.SNS
+}
+
+func NewSnsPublisher(awsSession *session.Session) SnsPublisher {
+	return SnsPublisher{
+		snsClient: sns.New(awsSession),
+	}
+}
+
+func (publisher SnsPublisher) Publish(workflowRecord workflows.WorkflowRecord, delay time.Duration) (messageId *string, err error) {
+	input, err := publishInput(workflowRecord)
+	if err != nil {
+		return
+	}
+	output, err := publisher.snsClient.Publish(input)
+	if err != nil {
+		return
+	}
+	messageId = output.MessageId
+	return
+}
+
+// publishInput carries the same attributes as the SQS message, fifo topics get the message group and deduplication ids as well.
+func publishInput(workflowRecord workflows.WorkflowRecord) (input *sns.PublishInput, err error) {
+	input = &sns.PublishInput{
+		Message:           aws.String(workflowRecord.Event),
+		TopicArn:          aws.String(workflowRecord.TargetQueueUrl),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{},
+	}
+	for name, value := range workflowRecord.MessageAttributes() {
+		input.MessageAttributes[name] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	if workflowRecord.IsFifo() {
+		var deduplicationId string
+		deduplicationId, err = workflowRecord.EventMessageDeduplicationId()
+		if err != nil {
+			return
+		}
+		input.MessageGroupId = aws.String(workflowRecord.EventMessageGroupId)
+		input.MessageDeduplicationId = aws.String(deduplicationId)
+	}
+	return
+}
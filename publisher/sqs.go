@@ -0,0 +1,67 @@
+package publisher
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/gitlotto/common/workflows"
+)
+
+// SqsPublisher sends the event to fifo and standard queues, the delay is only applied to standard ones.
+type SqsPublisher struct {
+	sqsClient *sqs.SQS
+}
+
+func NewSqsPublisher(awsSession *session.Session) SqsPublisher {
+	return SqsPublisher{
+		sqsClient: sqs.New(awsSession),
+	}
+}
+
+func (publisher SqsPublisher) Publish(workflowRecord workflows.WorkflowRecord, delay time.Duration) (messageId *string, err error) {
+	input, err := sendMessageInput(workflowRecord, delay)
+	if err != nil {
+		return
+	}
+	output, err := publisher.sqsClient.SendMessage(input)
+	if err != nil {
+		return
+	}
+	messageId = output.MessageId
+	return
+}
+
+// sendMessageInput builds the message of the workflow event. The delay is only applied to standard queues
+// since SQS does not support per-message delays in fifo queues.
+func sendMessageInput(workflowRecord workflows.WorkflowRecord, delay time.Duration) (input *sqs.SendMessageInput, err error) {
+	input = &sqs.SendMessageInput{
+		MessageBody:       aws.String(workflowRecord.Event),
+		QueueUrl:          aws.String(workflowRecord.TargetQueueUrl),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{},
+	}
+	for name, value := range workflowRecord.MessageAttributes() {
+		input.MessageAttributes[name] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	if workflowRecord.IsFifo() {
+		var deduplicationId string
+		deduplicationId, err = workflowRecord.EventMessageDeduplicationId()
+		if err != nil {
+			return
+		}
+		input.MessageGroupId = aws.String(workflowRecord.EventMessageGroupId)
+		input.MessageDeduplicationId = aws.String(deduplicationId)
+		return
+	}
+	if delay > workflows.MaxMessageDelay {
+		delay = workflows.MaxMessageDelay
+	}
+	if delay > 0 {
+		input.DelaySeconds = aws.Int64(int64(delay / time.Second))
+	}
+	return
+}
@@ -0,0 +1,25 @@
+module github.com/gitlotto/common/stream
+
+go 1.23.6
+
+require (
+	github.com/aws/aws-lambda-go v1.47.0
+	github.com/gitlotto/common/database v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.9.0
+	go.uber.org/zap v1.27.0
+)
+
+require (
+	github.com/aws/aws-sdk-go v1.50.28 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gitlotto/common/batcher v0.0.0-00010101000000-000000000000 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/gitlotto/common/batcher => ../batcher
+
+replace github.com/gitlotto/common/database => ../database
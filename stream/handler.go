@@ -0,0 +1,125 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/gitlotto/common/database"
+	"go.uber.org/zap"
+)
+
+func ErrNoHandlerForTable(tableName string) error {
+	return fmt.Errorf("there is no stream handler for the table %s", tableName)
+}
+
+type Notifier interface {
+	SendNotification(requestId string, message string) (err error)
+}
+
+type ChangeProcessor interface {
+	ProcessSingle(record events.DynamoDBEventRecord, logger *zap.Logger) (err error)
+}
+
+type ChangeCallback[R database.Record] func(change database.StreamChange[R], logger *zap.Logger) (err error)
+
+// Handler decodes the stream records of the table and passes them to the callback of the event.
+// Events without a callback are skipped.
+type Handler[R database.Record] struct {
+	Table    database.Table[R]
+	OnInsert ChangeCallback[R]
+	OnModify ChangeCallback[R]
+	OnRemove ChangeCallback[R]
+}
+
+func (handler Handler[R]) ProcessSingle(record events.DynamoDBEventRecord, logger *zap.Logger) (err error) {
+	var callback ChangeCallback[R]
+	switch events.DynamoDBOperationType(record.EventName) {
+	case events.DynamoDBOperationTypeInsert:
+		callback = handler.OnInsert
+	case events.DynamoDBOperationTypeModify:
+		callback = handler.OnModify
+	case events.DynamoDBOperationTypeRemove:
+		callback = handler.OnRemove
+	}
+	if callback == nil {
+		logger.Info("skipping the event without a callback", zap.String("eventName", record.EventName))
+		return
+	}
+
+	change, err := handler.Table.StreamChange(record)
+	if err != nil {
+		logger.Error("impossible to decode the stream record", zap.Error(err))
+		return
+	}
+	err = callback(change, logger)
+	return
+}
+
+// Router passes every stream record to the handler registered for the table the record comes from.
+type Router struct {
+	processors map[string]ChangeProcessor
+}
+
+func NewRouter() *Router {
+	return &Router{
+		processors: map[string]ChangeProcessor{},
+	}
+}
+
+func Register[R database.Record](router *Router, handler Handler[R]) *Router {
+	router.processors[handler.Table.Name] = handler
+	return router
+}
+
+func (router *Router) ProcessSingle(record events.DynamoDBEventRecord, logger *zap.Logger) (err error) {
+	tableName := TableNameOf(record.EventSourceArn)
+	processor, ok := router.processors[tableName]
+	if !ok {
+		return ErrNoHandlerForTable(tableName)
+	}
+	err = processor.ProcessSingle(record, logger.With(zap.String("tableName", tableName)))
+	return
+}
+
+// TableNameOf extracts the table name from the stream ARN arn:aws:dynamodb:region:account:table/name/stream/label
+func TableNameOf(eventSourceArn string) string {
+	_, resource, found := strings.Cut(eventSourceArn, ":table/")
+	if !found {
+		return ""
+	}
+	tableName, _, _ := strings.Cut(resource, "/stream/")
+	return tableName
+}
+
+// ProcessMultiple processes the records in order and stops on the first failure. The failed record is
+// reported as a batch item failure, so only it and the records after it are delivered again.
+func ProcessMultiple(
+	event events.DynamoDBEvent,
+	processor ChangeProcessor,
+	notifier Notifier,
+	logger *zap.Logger,
+) (response events.DynamoDBEventResponse) {
+
+	logger.Info("Processing stream records in total", zap.Int("records", len(event.Records)))
+
+	for _, record := range event.Records {
+		recordLogger := logger.With(zap.String("dynamodbEventID", record.EventID))
+		recordLogger.Info("processing single record ...")
+
+		errOfTheRecord := processor.ProcessSingle(record, recordLogger)
+		if errOfTheRecord != nil {
+			recordLogger.Error("impossible to process the stream record", zap.Error(errOfTheRecord))
+			if notifier != nil {
+				notifier.SendNotification(record.EventID, fmt.Sprintf("impossible to process the stream record %s", record.EventID))
+			}
+			response.BatchItemFailures = []events.DynamoDBBatchItemFailure{
+				{
+					ItemIdentifier: record.Change.SequenceNumber,
+				},
+			}
+			return
+		}
+	}
+	return
+}
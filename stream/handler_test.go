@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/gitlotto/common/database"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type someRecord struct {
+	Id    string `dynamodbav:"id"`
+	Value string `dynamodbav:"value"`
+}
+
+func (someRecord) ThePrimaryKey() database.PrimaryKey {
+	return database.PrimaryKey{}
+}
+
+const someTableArn = "arn:aws:dynamodb:us-east-1:000000000000:table/some_records/stream/2024-01-01T00:00:00.000"
+
+type recordingNotifier struct {
+	requestIds []string
+}
+
+func (notifier *recordingNotifier) SendNotification(requestId string, message string) (err error) {
+	notifier.requestIds = append(notifier.requestIds, requestId)
+	return
+}
+
+func someStreamRecord(eventId string, eventName string, sequenceNumber string, value string) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:        eventId,
+		EventName:      eventName,
+		EventSourceArn: someTableArn,
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: sequenceNumber,
+			OldImage: map[string]events.DynamoDBAttributeValue{
+				"id":    events.NewStringAttribute(eventId),
+				"value": events.NewStringAttribute("old " + value),
+			},
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"id":    events.NewStringAttribute(eventId),
+				"value": events.NewStringAttribute(value),
+			},
+		},
+	}
+}
+
+func Test_TableNameOf_should_extract_the_table_name_from_the_stream_arn(t *testing.T) {
+	assert.Equal(t, "some_records", TableNameOf(someTableArn))
+	assert.Equal(t, "", TableNameOf("arn:aws:sqs:us-east-1:000000000000:queue"))
+}
+
+func Test_Handler_should_pass_typed_images_to_the_callback_of_the_event(t *testing.T) {
+	var insertedRecords, modifiedRecords []someRecord
+	handler := Handler[someRecord]{
+		Table: database.Table[someRecord]{Name: "some_records"},
+		OnInsert: func(change database.StreamChange[someRecord], logger *zap.Logger) (err error) {
+			insertedRecords = append(insertedRecords, *change.NewImage)
+			return
+		},
+		OnModify: func(change database.StreamChange[someRecord], logger *zap.Logger) (err error) {
+			modifiedRecords = append(modifiedRecords, *change.OldImage, *change.NewImage)
+			return
+		},
+	}
+
+	logger := zap.NewNop()
+	assert.NoError(t, handler.ProcessSingle(someStreamRecord("1", "INSERT", "100", "first"), logger))
+	assert.NoError(t, handler.ProcessSingle(someStreamRecord("2", "MODIFY", "101", "second"), logger))
+	assert.NoError(t, handler.ProcessSingle(someStreamRecord("3", "REMOVE", "102", "third"), logger))
+
+	assert.Equal(t, []someRecord{{Id: "1", Value: "first"}}, insertedRecords)
+	assert.Equal(t, []someRecord{{Id: "2", Value: "old second"}, {Id: "2", Value: "second"}}, modifiedRecords)
+}
+
+func Test_ProcessMultiple_should_report_the_first_failed_record(t *testing.T) {
+	var processedIds []string
+	router := Register(NewRouter(), Handler[someRecord]{
+		Table: database.Table[someRecord]{Name: "some_records"},
+		OnInsert: func(change database.StreamChange[someRecord], logger *zap.Logger) (err error) {
+			processedIds = append(processedIds, change.NewImage.Id)
+			if change.NewImage.Value == "poison" {
+				err = errors.New("poison record")
+			}
+			return
+		},
+	})
+
+	event := events.DynamoDBEvent{
+		Records: []events.DynamoDBEventRecord{
+			someStreamRecord("1", "INSERT", "100", "fine"),
+			someStreamRecord("2", "INSERT", "101", "poison"),
+			someStreamRecord("3", "INSERT", "102", "fine"),
+		},
+	}
+	notifier := &recordingNotifier{}
+
+	response := ProcessMultiple(event, router, notifier, zap.NewNop())
+
+	assert.Equal(t, []events.DynamoDBBatchItemFailure{{ItemIdentifier: "101"}}, response.BatchItemFailures)
+	assert.Equal(t, []string{"1", "2"}, processedIds)
+	assert.Equal(t, []string{"2"}, notifier.requestIds)
+}
+
+func Test_ProcessMultiple_should_fail_records_of_unknown_tables(t *testing.T) {
+	record := someStreamRecord("1", "INSERT", "100", "fine")
+	record.EventSourceArn = "arn:aws:dynamodb:us-east-1:000000000000:table/unknown/stream/2024-01-01T00:00:00.000"
+
+	response := ProcessMultiple(events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{record}}, NewRouter(), nil, zap.NewNop())
+
+	assert.Equal(t, []events.DynamoDBBatchItemFailure{{ItemIdentifier: "100"}}, response.BatchItemFailures)
+}
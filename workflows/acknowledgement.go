@@ -0,0 +1,173 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/gitlotto/common/database"
+	"github.com/gitlotto/common/queue"
+	"github.com/gitlotto/common/zulu"
+	"go.uber.org/zap"
+)
+
+func ErrMissingWorkflowAttribute(name string) error {
+	return fmt.Errorf("message has no %s attribute of the workflow", name)
+}
+
+func ErrInvalidWorkflowAttribute(name string, value string) error {
+	return fmt.Errorf("message has an invalid %s attribute %q of the workflow", name, value)
+}
+
+func ErrUnknownSaga(sagaName string) error {
+	return fmt.Errorf("saga %s is not known to the acknowledging processor", sagaName)
+}
+
+// TransactionalEventProcessor includes its own writes into the transaction instead of executing them,
+// so they are persisted together with the closing of the workflow.
+type TransactionalEventProcessor interface {
+	ProcessSingleInTransaction(event *events.SQSMessage, transaction *database.Transaction, logger *zap.Logger) (err error)
+}
+
+// AcknowledgingProcessor finishes the workflow that has sent the message once the message is processed.
+// Messages of workflows that are finished already or do not exist anymore and messages of previous
+// occurrences of recurring workflows are duplicates and are skipped. Steps of sagas are completed
+// with their saga, which has to be given by WithSagas.
+type AcknowledgingProcessor struct {
+	table         WorkflowRecordTable
+	processor     queue.EventProcessor
+	transactional TransactionalEventProcessor
+	sagas         map[string]Saga
+}
+
+func Acknowledging(table WorkflowRecordTable, processor queue.EventProcessor) AcknowledgingProcessor {
+	return AcknowledgingProcessor{
+		table:     table,
+		processor: processor,
+	}
+}
+
+func AcknowledgingInTransaction(table WorkflowRecordTable, processor TransactionalEventProcessor) AcknowledgingProcessor {
+	return AcknowledgingProcessor{
+		table:         table,
+		transactional: processor,
+	}
+}
+
+// WithSagas lets the processor complete the steps of the given sagas, so what follows a step is scheduled.
+func (acknowledging AcknowledgingProcessor) WithSagas(sagas ...Saga) AcknowledgingProcessor {
+	acknowledging.sagas = map[string]Saga{}
+	for _, saga := range sagas {
+		acknowledging.sagas[saga.Name] = saga
+	}
+	return acknowledging
+}
+
+func (acknowledging AcknowledgingProcessor) ProcessSingle(event *events.SQSMessage, logger *zap.Logger) (err error) {
+	workflow, occurrence, err := WorkflowOfMessage(event)
+	if err != nil {
+		logger.Error("impossible to acknowledge the message", zap.Error(err))
+		return
+	}
+	logger = logger.With(zap.String("eventId", workflow.EventId), zap.String("targetQueueUrl", workflow.TargetQueueUrl))
+
+	err = acknowledging.table.Action(acknowledging.table.DynamodbClient).Reconstitute(&workflow)
+	if errors.Is(err, database.ErrNotFound) {
+		logger.Warn("skipping the message of a workflow that does not exist")
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if workflow.IsOpen == nil {
+		logger.Info("skipping the duplicate message of a finished workflow")
+		return
+	}
+	// the condition of finishing checks the occurrence as well, in case it changes before the processing is over
+	if occurrence != nil && *occurrence != workflow.Occurrence {
+		logger.Info("skipping the message of a previous occurrence", zap.Int("occurrence", *occurrence), zap.Int("currentOccurrence", workflow.Occurrence))
+		return
+	}
+
+	var saga Saga
+	if workflow.SagaPosition != nil {
+		var known bool
+		saga, known = acknowledging.sagas[workflow.SagaPosition.Saga]
+		if !known {
+			err = ErrUnknownSaga(workflow.SagaPosition.Saga)
+			logger.Error("impossible to acknowledge the step of the saga", zap.Error(err))
+			return
+		}
+	}
+
+	transaction := database.NewTransaction()
+	if acknowledging.transactional == nil {
+		err = acknowledging.processor.ProcessSingle(event, logger)
+	} else {
+		err = acknowledging.transactional.ProcessSingleInTransaction(event, transaction, logger)
+	}
+	if err != nil {
+		return
+	}
+
+	finishedAt := zulu.DateTimeFromTime(time.Now())
+	if workflow.SagaPosition != nil {
+		transaction = acknowledging.table.TransactCompleteSagaStep(transaction, saga, workflow, finishedAt, "")
+	} else {
+		transaction = transaction.Include(acknowledging.table.TransactionalFinish(workflow, finishedAt))
+	}
+	err = transaction.Execute(acknowledging.table.DynamodbClient)
+	if errors.Is(err, database.ErrConditionalCheckFailed) && acknowledging.hasBeenFinished(workflow) {
+		err = ErrWorkflowHadBeenFinished
+	}
+
+	if errors.Is(err, ErrWorkflowHadBeenFinished) {
+		logger.Info("skipping the duplicate message of a workflow finished in the meantime")
+		err = nil
+	}
+	return
+}
+
+// hasBeenFinished tells whether the failed condition of the transaction is the one of the workflow
+// and not one of the writes of the processor.
+func (acknowledging AcknowledgingProcessor) hasBeenFinished(workflow WorkflowRecord) bool {
+	current := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err := acknowledging.table.Action(acknowledging.table.DynamodbClient).Reconstitute(&current)
+	if err != nil {
+		return false
+	}
+	return current.IsOpen == nil || current.Occurrence != workflow.Occurrence
+}
+
+// WorkflowOfMessage is the key of the workflow taken from the message attributes set when sending its event.
+// The occurrence is nil for messages sent before it had been added to the attributes.
+func WorkflowOfMessage(event *events.SQSMessage) (workflow WorkflowRecord, occurrence *int, err error) {
+	for _, name := range []string{"EventId", "TargetQueueUrl"} {
+		attribute, ok := event.MessageAttributes[name]
+		if !ok || attribute.StringValue == nil || *attribute.StringValue == "" {
+			err = ErrMissingWorkflowAttribute(name)
+			return
+		}
+	}
+	workflow = WorkflowRecord{
+		EventId:        *event.MessageAttributes["EventId"].StringValue,
+		TargetQueueUrl: *event.MessageAttributes["TargetQueueUrl"].StringValue,
+	}
+
+	attribute, ok := event.MessageAttributes["Occurrence"]
+	if !ok || attribute.StringValue == nil {
+		return
+	}
+	value, errOfParsing := strconv.Atoi(*attribute.StringValue)
+	if errOfParsing != nil {
+		err = ErrInvalidWorkflowAttribute("Occurrence", *attribute.StringValue)
+		return
+	}
+	occurrence = &value
+	return
+}
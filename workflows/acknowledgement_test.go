@@ -0,0 +1,237 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gitlotto/common/database"
+	"github.com/gitlotto/common/zulu"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type countingProcessor struct {
+	processed int
+	err       error
+}
+
+func (processor *countingProcessor) ProcessSingle(event *events.SQSMessage, logger *zap.Logger) (err error) {
+	processor.processed++
+	return processor.err
+}
+
+type transactionalProcessor struct {
+	item *WorkflowRecord
+}
+
+func (processor transactionalProcessor) ProcessSingleInTransaction(event *events.SQSMessage, transaction *database.Transaction, logger *zap.Logger) (err error) {
+	transaction.Include(workflowRecordTable.TransactInsert(*processor.item))
+	return
+}
+
+func messageOf(workflow WorkflowRecord) *events.SQSMessage {
+	message := &events.SQSMessage{
+		Body:              workflow.Event,
+		MessageAttributes: map[string]events.SQSMessageAttribute{},
+	}
+	for name, value := range workflow.MessageAttributes() {
+		message.MessageAttributes[name] = events.SQSMessageAttribute{
+			DataType:    "String",
+			StringValue: aws.String(value),
+		}
+	}
+	return message
+}
+
+func Test_WorkflowOfMessage_should_be_read_from_the_message_attributes(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	workflow.Occurrence = 3
+
+	actualWorkflow, occurrence, err := WorkflowOfMessage(messageOf(workflow))
+	assert.NoError(t, err)
+	assert.Equal(t, WorkflowRecord{EventId: workflow.EventId, TargetQueueUrl: workflow.TargetQueueUrl}, actualWorkflow)
+	assert.Equal(t, aws.Int(3), occurrence)
+
+	message := messageOf(workflow)
+	delete(message.MessageAttributes, "Occurrence")
+	_, occurrence, err = WorkflowOfMessage(message)
+	assert.NoError(t, err)
+	assert.Nil(t, occurrence)
+
+	message.MessageAttributes["Occurrence"] = events.SQSMessageAttribute{DataType: "String", StringValue: aws.String("third")}
+	_, _, err = WorkflowOfMessage(message)
+	assert.Equal(t, ErrInvalidWorkflowAttribute("Occurrence", "third"), err)
+
+	delete(message.MessageAttributes, "TargetQueueUrl")
+	_, _, err = WorkflowOfMessage(message)
+	assert.Equal(t, ErrMissingWorkflowAttribute("TargetQueueUrl"), err)
+}
+
+func Test_AcknowledgingProcessor_should_close_the_workflow_once_the_message_is_processed(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	processor := &countingProcessor{}
+	acknowledging := Acknowledging(workflowRecordTable, processor)
+
+	err = acknowledging.ProcessSingle(messageOf(workflow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = acknowledging.ProcessSingle(messageOf(workflow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processor.processed)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFinished, actualWorkflow.Status())
+}
+
+func Test_AcknowledgingProcessor_should_keep_the_workflow_open_if_the_processing_fails(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	errOfProcessing := errors.New("processing failed")
+	acknowledging := Acknowledging(workflowRecordTable, &countingProcessor{err: errOfProcessing})
+
+	err = acknowledging.ProcessSingle(messageOf(workflow), zap.NewNop())
+	assert.ErrorIs(t, err, errOfProcessing)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, actualWorkflow.Status())
+}
+
+func Test_AcknowledgingProcessor_should_close_the_workflow_in_the_transaction_of_the_processor(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	written := makeWorkflowRecord(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	acknowledging := AcknowledgingInTransaction(workflowRecordTable, transactionalProcessor{item: &written})
+
+	err = acknowledging.ProcessSingle(messageOf(workflow), zap.NewNop())
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFinished, actualWorkflow.Status())
+
+	actualWritten := WorkflowRecord{
+		EventId:        written.EventId,
+		TargetQueueUrl: written.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWritten)
+	assert.NoError(t, err)
+	assert.Equal(t, written, actualWritten)
+}
+
+func Test_AcknowledgingProcessor_should_skip_the_message_of_a_previous_occurrence(t *testing.T) {
+	var err error
+
+	schedule, err := NewIntervalSchedule(time.Hour)
+	assert.NoError(t, err)
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	WithSchedule(schedule)(&workflow)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	processor := &countingProcessor{}
+	acknowledging := Acknowledging(workflowRecordTable, processor)
+
+	err = acknowledging.ProcessSingle(messageOf(workflow), zap.NewNop())
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, actualWorkflow.Occurrence)
+	rescheduledStartAt := actualWorkflow.StartAt
+
+	// the message of the first occurrence is redelivered while the second one is open
+	err = acknowledging.ProcessSingle(messageOf(workflow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processor.processed)
+
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, actualWorkflow.Occurrence)
+	assert.Equal(t, rescheduledStartAt, actualWorkflow.StartAt)
+	assert.Equal(t, StatusOpen, actualWorkflow.Status())
+}
+
+func Test_AcknowledgingProcessor_should_skip_the_message_of_a_workflow_that_does_not_exist(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+
+	processor := &countingProcessor{}
+	acknowledging := Acknowledging(workflowRecordTable, processor)
+
+	err := acknowledging.ProcessSingle(messageOf(workflow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, processor.processed)
+}
+
+func Test_AcknowledgingProcessor_should_complete_the_step_of_a_saga(t *testing.T) {
+	var err error
+
+	saga := makeLotterySaga()
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	reserve, err := saga.Start(uuid.New().String(), uuid.New().String(), nil, createdAt, createdAt, "event", "group")
+	assert.NoError(t, err)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(*reserve)
+	assert.NoError(t, err)
+
+	processor := &countingProcessor{}
+	err = Acknowledging(workflowRecordTable, processor).ProcessSingle(messageOf(*reserve), zap.NewNop())
+	assert.Equal(t, ErrUnknownSaga(saga.Name), err)
+	assert.Equal(t, 0, processor.processed)
+
+	acknowledging := Acknowledging(workflowRecordTable, processor).WithSagas(saga)
+	err = acknowledging.ProcessSingle(messageOf(*reserve), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, processor.processed)
+
+	actualReserve := WorkflowRecord{
+		EventId:        reserve.EventId,
+		TargetQueueUrl: reserve.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualReserve)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFinished, actualReserve.Status())
+
+	charge := WorkflowRecord{
+		EventId:        reserve.EventId,
+		TargetQueueUrl: saga.Steps[1].ActionQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&charge)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, charge.Status())
+	assert.Equal(t, &SagaPosition{Saga: saga.Name, Step: 1}, charge.SagaPosition)
+}
@@ -0,0 +1,91 @@
+package workflows
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/gitlotto/common/zulu"
+)
+
+// attemptsHistoryLimit bounds the attempts kept on the record so that it stays far below the item size limit.
+const attemptsHistoryLimit = 20
+
+type AttemptOutcome string
+
+const (
+	AttemptSent   AttemptOutcome = "SENT"
+	AttemptFailed AttemptOutcome = "FAILED"
+)
+
+type Attempt struct {
+	SentAt         zulu.DateTime  `dynamodbav:"sent_at"`
+	TargetQueueUrl string         `dynamodbav:"target_queue_url"`
+	Outcome        AttemptOutcome `dynamodbav:"outcome"`
+	MessageId      *string        `dynamodbav:"message_id,omitempty"`
+	Error          *string        `dynamodbav:"error,omitempty"`
+}
+
+func NewAttempt(workflow WorkflowRecord, sentAt zulu.DateTime, messageId *string, errOfSending error) Attempt {
+	attempt := Attempt{
+		SentAt:         sentAt,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+		Outcome:        AttemptSent,
+		MessageId:      messageId,
+	}
+	if errOfSending != nil {
+		attempt.Outcome = AttemptFailed
+		attempt.Error = aws.String(errOfSending.Error())
+	}
+	return attempt
+}
+
+// PostponeWithAttempt postpones the workflow like Postpone and appends the attempt to its history,
+// dropping the oldest attempts beyond the limit.
+func (table WorkflowRecordTable) PostponeWithAttempt(workflow WorkflowRecord, nextStartAt zulu.DateTime, attempt Attempt) (err error) {
+	attempts := append(append([]Attempt{}, workflow.Attempts...), attempt)
+	if len(attempts) > attemptsHistoryLimit {
+		attempts = attempts[len(attempts)-attemptsHistoryLimit:]
+	}
+	attemptsValue, err := dynamodbattribute.Marshal(attempts)
+	if err != nil {
+		return
+	}
+
+	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(table.Table.Name),
+		Key: map[string]*dynamodb.AttributeValue{
+			"event_id": {
+				S: aws.String(workflow.EventId),
+			},
+			"target_queue_url": {
+				S: aws.String(workflow.TargetQueueUrl),
+			},
+		},
+		UpdateExpression: aws.String("SET start_at = :start_at, attempts = :attempts ADD amount_of_starts :by_one REMOVE lease_owner, lease_expires_at"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":start_at": {
+				S: aws.String(nextStartAt.String()),
+			},
+			":attempts": attemptsValue,
+			":by_one": {
+				N: aws.String("1"),
+			},
+		},
+		ConditionExpression: aws.String("attribute_exists(is_open)"),
+	})
+	return refineConditionalCheckFailure(err)
+}
+
+// History returns the latest attempts of the workflow, the oldest first.
+func (table WorkflowRecordTable) History(eventId string, targetQueueUrl string) (attempts []Attempt, err error) {
+	workflow := WorkflowRecord{
+		EventId:        eventId,
+		TargetQueueUrl: targetQueueUrl,
+	}
+	err = table.Action(table.DynamodbClient).Reconstitute(&workflow)
+	if err != nil {
+		return
+	}
+	attempts = workflow.Attempts
+	return
+}
@@ -0,0 +1,67 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gitlotto/common/zulu"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Attempt_should_keep_the_outcome_of_sending(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	sentAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 46, 0, 0, time.UTC))
+
+	sentAttempt := NewAttempt(workflow, sentAt, aws.String("message id"), nil)
+	expectedSentAttempt := Attempt{
+		SentAt:         sentAt,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+		Outcome:        AttemptSent,
+		MessageId:      aws.String("message id"),
+	}
+	assert.Equal(t, expectedSentAttempt, sentAttempt)
+
+	failedAttempt := NewAttempt(workflow, sentAt, nil, errors.New("queue does not exist"))
+	expectedFailedAttempt := Attempt{
+		SentAt:         sentAt,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+		Outcome:        AttemptFailed,
+		Error:          aws.String("queue does not exist"),
+	}
+	assert.Equal(t, expectedFailedAttempt, failedAttempt)
+}
+
+func Test_WorkflowRecordTable_should_keep_a_bounded_history_of_attempts(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	expectedAttempts := []Attempt{}
+	for i := 0; i < attemptsHistoryLimit+3; i++ {
+		sentAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 13, i, 0, 0, time.UTC))
+		attempt := NewAttempt(workflow, sentAt, aws.String(sentAt.String()), nil)
+		expectedAttempts = append(expectedAttempts, attempt)
+
+		err = workflowRecordTable.PostponeWithAttempt(workflow, sentAt, attempt)
+		assert.NoError(t, err)
+
+		workflow.Attempts, err = workflowRecordTable.History(workflow.EventId, workflow.TargetQueueUrl)
+		assert.NoError(t, err)
+	}
+
+	actualAttempts, err := workflowRecordTable.History(workflow.EventId, workflow.TargetQueueUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedAttempts[3:], actualAttempts)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, attemptsHistoryLimit+3, actualWorkflow.AmountOfStarts)
+}
@@ -9,6 +9,8 @@ import (
 
 const workflowsTableName = "workflows-workflows"
 const openWorkflowsIndexName = "workflows-openWorkflows"
+const failedWorkflowsIndexName = "workflows-failedWorkflows"
+const targetQueueWorkflowsIndexName = "workflows-targetQueueWorkflows"
 
 var awsConfig = aws.Config{
 	Region:     aws.String("us-east-1"),
@@ -37,6 +39,18 @@ var openWorkflowsIndex = OpenWorkflowsIndex{
 	DynamodbClient: dynamodbClient,
 }
 
+var failedWorkflowsIndex = FailedWorkflowsIndex{
+	TableName:      workflowsTableName,
+	IndexName:      failedWorkflowsIndexName,
+	DynamodbClient: dynamodbClient,
+}
+
+var targetQueueIndex = TargetQueueIndex{
+	TableName:      workflowsTableName,
+	IndexName:      targetQueueWorkflowsIndexName,
+	DynamodbClient: dynamodbClient,
+}
+
 func deleteAllWorkflows() (err error) {
 	workflows, err := dynamodbClient.Scan(&dynamodb.ScanInput{
 		TableName: aws.String(workflowsTableName),
@@ -0,0 +1,96 @@
+package workflows
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func ErrDuplicatedDeduplication(name string) error {
+	return fmt.Errorf("deduplication %s has been registered already", name)
+}
+
+func ErrUnregisteredDeduplication(strategy DeduplicationStrategy) error {
+	return fmt.Errorf("deduplication %s has not been registered", strategy)
+}
+
+// DeduplicationStrategy decides what makes two messages of a fifo workflow the same for SQS.
+type DeduplicationStrategy string
+
+const (
+	// DeduplicateByEventId sends the event once per occurrence and redrive of the workflow.
+	DeduplicateByEventId DeduplicationStrategy = "EVENT_ID"
+	// DeduplicateByAttempt lets every attempt through, so a retry within the deduplication window is not dropped.
+	DeduplicateByAttempt DeduplicationStrategy = "EVENT_ID_AND_ATTEMPT"
+	// DeduplicateByContent lets the event through again once its payload has changed.
+	DeduplicateByContent DeduplicationStrategy = "CONTENT"
+)
+
+const customDeduplicationPrefix = "CUSTOM#"
+
+type DeduplicationFunction func(record WorkflowRecord) string
+
+var customDeduplications = struct {
+	sync.RWMutex
+	functions map[string]DeduplicationFunction
+}{
+	functions: map[string]DeduplicationFunction{},
+}
+
+// RegisterDeduplication makes the function available by its name. Only the name is stored on the record,
+// so every process sending the workflow, the outboxer and direct_pass included, has to register it as well.
+func RegisterDeduplication(name string, function DeduplicationFunction) (strategy DeduplicationStrategy, err error) {
+	customDeduplications.Lock()
+	defer customDeduplications.Unlock()
+	if _, registered := customDeduplications.functions[name]; registered {
+		err = ErrDuplicatedDeduplication(name)
+		return
+	}
+	customDeduplications.functions[name] = function
+	strategy = DeduplicationStrategy(customDeduplicationPrefix + name)
+	return
+}
+
+func WithDeduplication(strategy DeduplicationStrategy) WorkflowOption {
+	return func(workflowRecord *WorkflowRecord) {
+		workflowRecord.Deduplication = strategy
+	}
+}
+
+// deduplicationSource is hashed into the deduplication id. A custom function that is not registered
+// in this process is an error, sending the event with another deduplication id could drop or duplicate it.
+func (record WorkflowRecord) deduplicationSource() (source string, err error) {
+	switch strategy := record.Deduplication; {
+	case strategy == "" || strategy == DeduplicateByEventId:
+		source = record.eventIdSource()
+	case strategy == DeduplicateByAttempt:
+		source = record.eventIdSource() + "#attempt" + strconv.Itoa(record.AmountOfStarts)
+	case strategy == DeduplicateByContent:
+		source = record.eventIdSource() + "#" + record.Event
+	case strings.HasPrefix(string(strategy), customDeduplicationPrefix):
+		customDeduplications.RLock()
+		function, registered := customDeduplications.functions[strings.TrimPrefix(string(strategy), customDeduplicationPrefix)]
+		customDeduplications.RUnlock()
+		if !registered {
+			err = ErrUnregisteredDeduplication(strategy)
+			return
+		}
+		source = function(record)
+	default:
+		err = ErrUnregisteredDeduplication(strategy)
+	}
+	return
+}
+
+// eventIdSource stays the bare event id for workflows that have been neither rescheduled nor redriven.
+func (record WorkflowRecord) eventIdSource() string {
+	source := record.EventId
+	if record.Occurrence > 0 {
+		source += "#" + strconv.Itoa(record.Occurrence)
+	}
+	if record.AmountOfRedrives > 0 {
+		source += "#redrive" + strconv.Itoa(record.AmountOfRedrives)
+	}
+	return source
+}
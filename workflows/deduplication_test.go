@@ -0,0 +1,84 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Deduplication_by_event_id_should_stay_the_same_for_every_attempt(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	firstDeduplicationId := deduplicationIdOf(t, workflow)
+
+	workflow.AmountOfStarts = 1
+	workflow.Event = "changed event"
+	assert.Equal(t, firstDeduplicationId, deduplicationIdOf(t, workflow))
+
+	WithDeduplication(DeduplicateByEventId)(&workflow)
+	assert.Equal(t, firstDeduplicationId, deduplicationIdOf(t, workflow))
+
+	workflow.AmountOfRedrives = 1
+	assert.NotEqual(t, firstDeduplicationId, deduplicationIdOf(t, workflow))
+}
+
+func Test_Deduplication_by_attempt_should_differ_for_every_attempt(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	WithDeduplication(DeduplicateByAttempt)(&workflow)
+	firstDeduplicationId := deduplicationIdOf(t, workflow)
+
+	workflow.AmountOfStarts = 1
+	secondDeduplicationId := deduplicationIdOf(t, workflow)
+	assert.NotEqual(t, firstDeduplicationId, secondDeduplicationId)
+
+	workflow.Event = "changed event"
+	assert.Equal(t, secondDeduplicationId, deduplicationIdOf(t, workflow))
+}
+
+func Test_Deduplication_by_content_should_differ_for_a_changed_event(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	WithDeduplication(DeduplicateByContent)(&workflow)
+	firstDeduplicationId := deduplicationIdOf(t, workflow)
+
+	workflow.AmountOfStarts = 1
+	assert.Equal(t, firstDeduplicationId, deduplicationIdOf(t, workflow))
+
+	workflow.Event = "changed event"
+	assert.NotEqual(t, firstDeduplicationId, deduplicationIdOf(t, workflow))
+}
+
+func Test_Deduplication_should_use_the_registered_function(t *testing.T) {
+	strategy, err := RegisterDeduplication("by group", func(record WorkflowRecord) string {
+		return record.EventMessageGroupId
+	})
+	assert.NoError(t, err)
+
+	_, err = RegisterDeduplication("by group", nil)
+	assert.Equal(t, ErrDuplicatedDeduplication("by group"), err)
+
+	first := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	second := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	second.EventMessageGroupId = first.EventMessageGroupId
+	WithDeduplication(strategy)(&first)
+	WithDeduplication(strategy)(&second)
+	assert.Equal(t, deduplicationIdOf(t, first), deduplicationIdOf(t, second))
+	assert.NotEqual(t, deduplicationIdOf(t, first), deduplicationIdOf(t, makeWorkflowRecord(time.Now())))
+}
+
+func Test_Deduplication_should_fail_if_the_function_is_not_registered(t *testing.T) {
+	strategy := DeduplicationStrategy(customDeduplicationPrefix + "unknown")
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	WithDeduplication(strategy)(&workflow)
+
+	_, err := workflow.EventMessageDeduplicationId()
+	assert.Equal(t, ErrUnregisteredDeduplication(strategy), err)
+
+	_, err = NewFifoWorkflowRecord("table", "partition", nil, workflow.CreatedAt, workflow.StartAt, "queue.fifo", "event", "group", WithDeduplication(strategy))
+	assert.Equal(t, ErrUnregisteredDeduplication(strategy), err)
+}
+
+func deduplicationIdOf(t *testing.T, workflow WorkflowRecord) string {
+	deduplicationId, err := workflow.EventMessageDeduplicationId()
+	assert.NoError(t, err)
+	return deduplicationId
+}
@@ -0,0 +1,50 @@
+package workflows
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/gitlotto/common/database"
+)
+
+type FailedWorkflowsIndex struct {
+	TableName      string
+	IndexName      string
+	DynamodbClient *dynamodb.DynamoDB
+}
+
+// FailedWorkflows reads the failed workflows, the most recently failed first.
+func (index FailedWorkflowsIndex) FailedWorkflows(limit int, cursor *string) (workflowRecords []WorkflowRecord, nextCursor *string, err error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              &index.TableName,
+		IndexName:              &index.IndexName,
+		KeyConditionExpression: aws.String("is_failed = :is_failed"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":is_failed": {
+				S: aws.String(string(Failed)),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(int64(limit)),
+	}
+
+	if cursor != nil {
+		queryInput.ExclusiveStartKey, err = database.DecodeCursor(*cursor)
+		if err != nil {
+			return
+		}
+	}
+
+	items, err := index.DynamodbClient.Query(queryInput)
+	if err != nil {
+		return
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(items.Items, &workflowRecords)
+	if err != nil {
+		return
+	}
+
+	nextCursor, err = database.EncodeCursor(items.LastEvaluatedKey)
+	return
+}
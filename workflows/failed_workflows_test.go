@@ -0,0 +1,46 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gitlotto/common/zulu"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FailedWorkflowsIndex_should_read_the_most_recently_failed_workflows_page_by_page(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	openWorkflow := makeWorkflowRecord(time.Date(2023, time.September, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(openWorkflow)
+	assert.NoError(t, err)
+
+	failedWorkflows := []WorkflowRecord{}
+	for day := 17; day <= 19; day++ {
+		failedWorkflow := makeWorkflowRecord(time.Date(2023, time.September, 16, 12, 45, 14, 0, time.UTC))
+		err = workflowRecordTable.Action(dynamodbClient).Persist(failedWorkflow)
+		assert.NoError(t, err)
+
+		failedAt := zulu.DateTimeFromTime(time.Date(2023, time.September, day, 12, 45, 14, 0, time.UTC))
+		err = workflowRecordTable.Fail(failedWorkflow, failedAt, "exhausted attempts")
+		assert.NoError(t, err)
+		failedWorkflows = append(failedWorkflows, failedWorkflow)
+	}
+
+	actualFirstPage, cursor, err := failedWorkflowsIndex.FailedWorkflows(2, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, cursor)
+	assert.Len(t, actualFirstPage, 2)
+	assert.Equal(t, failedWorkflows[2].EventId, actualFirstPage[0].EventId)
+	assert.Equal(t, failedWorkflows[1].EventId, actualFirstPage[1].EventId)
+
+	actualSecondPage, cursor, err := failedWorkflowsIndex.FailedWorkflows(2, cursor)
+	assert.NoError(t, err)
+	assert.Nil(t, cursor)
+	assert.Len(t, actualSecondPage, 1)
+	assert.Equal(t, failedWorkflows[0].EventId, actualSecondPage[0].EventId)
+	assert.Equal(t, "exhausted attempts", *actualSecondPage[0].FailureReason)
+}
@@ -0,0 +1,103 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gitlotto/common/database"
+	"github.com/gitlotto/common/zulu"
+)
+
+var ErrNoFanOutTargets = errors.New("fan-out workflow should have at least one target")
+
+func ErrDuplicatedFanOutTarget(targetQueueUrl string) error {
+	return fmt.Errorf("fan-out workflow has the target %s more than once", targetQueueUrl)
+}
+
+// FanOutTarget is a queue the event is delivered to. The message group id is only used by fifo queues.
+type FanOutTarget struct {
+	TargetQueueUrl      string
+	EventMessageGroupId string
+}
+
+// NewFanOutWorkflowRecords creates one workflow of the same event per target.
+func NewFanOutWorkflowRecords(
+	tableName string,
+	partitionKey string,
+	sortKey *string,
+	createdAt zulu.DateTime,
+	startAt zulu.DateTime,
+	targets []FanOutTarget,
+	event string,
+	options ...WorkflowOption,
+) (workflowRecords []WorkflowRecord, err error) {
+	if len(targets) == 0 {
+		return nil, ErrNoFanOutTargets
+	}
+	seenTargets := map[string]bool{}
+	for _, target := range targets {
+		if seenTargets[target.TargetQueueUrl] {
+			return nil, ErrDuplicatedFanOutTarget(target.TargetQueueUrl)
+		}
+		seenTargets[target.TargetQueueUrl] = true
+
+		var workflowRecord *WorkflowRecord
+		if strings.HasSuffix(target.TargetQueueUrl, ".fifo") {
+			workflowRecord, err = NewFifoWorkflowRecord(tableName, partitionKey, sortKey, createdAt, startAt, target.TargetQueueUrl, event, target.EventMessageGroupId, options...)
+		} else {
+			workflowRecord, err = NewStandardWorkflowRecord(tableName, partitionKey, sortKey, createdAt, startAt, target.TargetQueueUrl, event, options...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		workflowRecords = append(workflowRecords, *workflowRecord)
+	}
+	return
+}
+
+// TransactFanOut includes the insertion of every workflow into the transaction, so either all of the targets get the event or none.
+func (table WorkflowRecordTable) TransactFanOut(transaction *database.Transaction, workflowRecords []WorkflowRecord) *database.Transaction {
+	for _, workflowRecord := range workflowRecords {
+		transaction.Include(table.TransactInsert(workflowRecord))
+	}
+	return transaction
+}
+
+func (table WorkflowRecordTable) FanOut(workflowRecords []WorkflowRecord) (err error) {
+	return table.TransactFanOut(database.NewTransaction(), workflowRecords).Execute(table.DynamodbClient)
+}
+
+// WorkflowsOfEvent reads the workflows of every target of the event.
+func (table WorkflowRecordTable) WorkflowsOfEvent(eventId string) (workflowRecords []WorkflowRecord, err error) {
+	partitionKey := database.DynamodbKey{
+		Name:  "event_id",
+		Value: eventId,
+		Type:  database.KeyTypeString,
+	}
+	var cursor *string
+	for {
+		var page []WorkflowRecord
+		page, cursor, err = table.Action(table.DynamodbClient).Query(partitionKey, cursor, 100)
+		if err != nil {
+			return
+		}
+		workflowRecords = append(workflowRecords, page...)
+		if cursor == nil {
+			return
+		}
+	}
+}
+
+// StatusesOfEvent maps every target queue of the event to the status of its workflow.
+func (table WorkflowRecordTable) StatusesOfEvent(eventId string) (statuses map[string]WorkflowStatus, err error) {
+	workflowRecords, err := table.WorkflowsOfEvent(eventId)
+	if err != nil {
+		return
+	}
+	statuses = make(map[string]WorkflowStatus, len(workflowRecords))
+	for _, workflowRecord := range workflowRecords {
+		statuses[workflowRecord.TargetQueueUrl] = workflowRecord.Status()
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gitlotto/common/database"
+	"github.com/gitlotto/common/zulu"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FanOut_should_create_a_workflow_per_target(t *testing.T) {
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	startAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	fifoQueueUrl := uuid.New().String() + ".fifo"
+	standardQueueUrl := uuid.New().String()
+	targets := []FanOutTarget{
+		{TargetQueueUrl: fifoQueueUrl, EventMessageGroupId: "group"},
+		{TargetQueueUrl: standardQueueUrl},
+	}
+
+	workflowRecords, err := NewFanOutWorkflowRecords("table", "partition", nil, createdAt, startAt, targets, "event")
+	assert.NoError(t, err)
+	assert.Len(t, workflowRecords, 2)
+
+	assert.Equal(t, "table#partition", workflowRecords[0].EventId)
+	assert.Equal(t, fifoQueueUrl, workflowRecords[0].TargetQueueUrl)
+	assert.Equal(t, FifoQueue, workflowRecords[0].QueueKind)
+	assert.Equal(t, "group", workflowRecords[0].EventMessageGroupId)
+
+	assert.Equal(t, "table#partition", workflowRecords[1].EventId)
+	assert.Equal(t, standardQueueUrl, workflowRecords[1].TargetQueueUrl)
+	assert.Equal(t, StandardQueue, workflowRecords[1].QueueKind)
+}
+
+func Test_FanOut_should_not_have_the_same_target_twice(t *testing.T) {
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	targets := []FanOutTarget{{TargetQueueUrl: "queue"}, {TargetQueueUrl: "queue"}}
+
+	workflowRecords, err := NewFanOutWorkflowRecords("table", "partition", nil, createdAt, createdAt, targets, "event")
+	assert.Nil(t, workflowRecords)
+	assert.Equal(t, ErrDuplicatedFanOutTarget("queue"), err)
+
+	workflowRecords, err = NewFanOutWorkflowRecords("table", "partition", nil, createdAt, createdAt, nil, "event")
+	assert.Nil(t, workflowRecords)
+	assert.Equal(t, ErrNoFanOutTargets, err)
+}
+
+func Test_WorkflowRecordTable_should_fan_out_the_event_in_a_single_transaction(t *testing.T) {
+	var err error
+
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	startAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	targets := []FanOutTarget{
+		{TargetQueueUrl: uuid.New().String() + ".fifo", EventMessageGroupId: uuid.New().String()},
+		{TargetQueueUrl: uuid.New().String()},
+		{TargetQueueUrl: uuid.New().String()},
+	}
+
+	workflowRecords, err := NewFanOutWorkflowRecords(uuid.New().String(), uuid.New().String(), nil, createdAt, startAt, targets, "event")
+	assert.NoError(t, err)
+
+	err = workflowRecordTable.FanOut(workflowRecords)
+	assert.NoError(t, err)
+
+	err = workflowRecordTable.FanOut(workflowRecords)
+	assert.ErrorIs(t, err, database.ErrConditionalCheckFailed)
+
+	err = workflowRecordTable.Close(workflowRecords[1].EventId, workflowRecords[1].TargetQueueUrl, startAt)
+	assert.NoError(t, err)
+
+	actualWorkflowRecords, err := workflowRecordTable.WorkflowsOfEvent(workflowRecords[0].EventId)
+	assert.NoError(t, err)
+	assert.Len(t, actualWorkflowRecords, 3)
+
+	actualStatuses, err := workflowRecordTable.StatusesOfEvent(workflowRecords[0].EventId)
+	assert.NoError(t, err)
+
+	expectedStatuses := map[string]WorkflowStatus{
+		targets[0].TargetQueueUrl: StatusOpen,
+		targets[1].TargetQueueUrl: StatusFinished,
+		targets[2].TargetQueueUrl: StatusOpen,
+	}
+	assert.Equal(t, expectedStatuses, actualStatuses)
+}
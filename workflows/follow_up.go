@@ -0,0 +1,93 @@
+package workflows
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/gitlotto/common/database"
+	"github.com/gitlotto/common/zulu"
+)
+
+var ErrRecurringWorkflowWithResult = errors.New("recurring workflow cannot be closed with a result")
+
+// TransactionalCloseWithResult closes the workflow for good, so it rejects recurring workflows,
+// which are rescheduled by Close and Finish instead.
+func (table WorkflowRecordTable) TransactionalCloseWithResult(
+	worflowEventId string,
+	workflowTargetQueueUrl string,
+	finishedAt zulu.DateTime,
+	result string,
+) (item *dynamodb.TransactWriteItem, err error) {
+	workflow := WorkflowRecord{
+		EventId:        worflowEventId,
+		TargetQueueUrl: workflowTargetQueueUrl,
+	}
+	err = table.Action(table.DynamodbClient).Reconstitute(&workflow)
+	if errors.Is(err, database.ErrNotFound) {
+		// the condition of closing fails for a missing workflow as well
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	if workflow.Schedule != nil {
+		return nil, ErrRecurringWorkflowWithResult
+	}
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(table.Table.Name),
+			Key: map[string]*dynamodb.AttributeValue{
+				"event_id": {
+					S: aws.String(worflowEventId),
+				},
+				"target_queue_url": {
+					S: aws.String(workflowTargetQueueUrl),
+				},
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":finished_at": {
+					S: aws.String(finishedAt.String()),
+				},
+				":result": {
+					S: aws.String(result),
+				},
+				":workflow_state": StatusFinished.attributeValue(),
+			},
+			UpdateExpression:    aws.String("SET finished_at = :finished_at, #result = :result, workflow_state = :workflow_state REMOVE is_open, lease_owner, lease_expires_at"),
+			ConditionExpression: aws.String("attribute_exists(is_open)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#result": aws.String("result"),
+			},
+		},
+	}, nil
+}
+
+// TransactCloseAndFollowUp includes closing the workflow with its result and creating the follow-up workflow
+// into the transaction, so the next step is scheduled if and only if the current one is finished.
+func (table WorkflowRecordTable) TransactCloseAndFollowUp(
+	transaction *database.Transaction,
+	worflowEventId string,
+	workflowTargetQueueUrl string,
+	finishedAt zulu.DateTime,
+	result string,
+	followUp WorkflowRecord,
+) *database.Transaction {
+	followUp.ParentEventId = aws.String(worflowEventId)
+	followUp.ParentTargetQueueUrl = aws.String(workflowTargetQueueUrl)
+	return transaction.
+		Include(table.TransactionalCloseWithResult(worflowEventId, workflowTargetQueueUrl, finishedAt, result)).
+		Include(table.TransactInsert(followUp))
+}
+
+func (table WorkflowRecordTable) CloseAndFollowUp(
+	worflowEventId string,
+	workflowTargetQueueUrl string,
+	finishedAt zulu.DateTime,
+	result string,
+	followUp WorkflowRecord,
+) (err error) {
+	return table.
+		TransactCloseAndFollowUp(database.NewTransaction(), worflowEventId, workflowTargetQueueUrl, finishedAt, result, followUp).
+		Execute(table.DynamodbClient)
+}
@@ -0,0 +1,99 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gitlotto/common/database"
+	"github.com/gitlotto/common/zulu"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WorkflowRecordTable_should_close_the_workflow_with_its_result_and_follow_it_up(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	followUp := makeWorkflowRecord(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 13, 0, 0, 0, time.UTC))
+
+	err = workflowRecordTable.CloseAndFollowUp(workflow.EventId, workflow.TargetQueueUrl, finishedAt, `{"charged":true}`, followUp)
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+
+	expectedWorkflow := workflow
+	expectedWorkflow.IsOpen = nil
+	expectedWorkflow.FinishedAt = &finishedAt
+	expectedWorkflow.Result = aws.String(`{"charged":true}`)
+	expectedWorkflow.State = StatusFinished
+	assert.Equal(t, expectedWorkflow, actualWorkflow)
+
+	actualFollowUp := WorkflowRecord{
+		EventId:        followUp.EventId,
+		TargetQueueUrl: followUp.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualFollowUp)
+	assert.NoError(t, err)
+
+	expectedFollowUp := followUp
+	expectedFollowUp.ParentEventId = aws.String(workflow.EventId)
+	expectedFollowUp.ParentTargetQueueUrl = aws.String(workflow.TargetQueueUrl)
+	assert.Equal(t, expectedFollowUp, actualFollowUp)
+}
+
+func Test_WorkflowRecordTable_should_not_follow_up_the_workflow_if_it_had_been_closed(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 13, 0, 0, 0, time.UTC))
+	err = workflowRecordTable.Close(workflow.EventId, workflow.TargetQueueUrl, finishedAt)
+	assert.NoError(t, err)
+
+	followUp := makeWorkflowRecord(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.CloseAndFollowUp(workflow.EventId, workflow.TargetQueueUrl, finishedAt, "result", followUp)
+	assert.ErrorIs(t, err, database.ErrConditionalCheckFailed)
+
+	actualFollowUp := WorkflowRecord{
+		EventId:        followUp.EventId,
+		TargetQueueUrl: followUp.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualFollowUp)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func Test_WorkflowRecordTable_should_not_close_a_recurring_workflow_with_a_result(t *testing.T) {
+	var err error
+
+	schedule, err := NewIntervalSchedule(time.Hour)
+	assert.NoError(t, err)
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	WithSchedule(schedule)(&workflow)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	followUp := makeWorkflowRecord(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 13, 0, 0, 0, time.UTC))
+	err = workflowRecordTable.CloseAndFollowUp(workflow.EventId, workflow.TargetQueueUrl, finishedAt, "result", followUp)
+	assert.ErrorIs(t, err, ErrRecurringWorkflowWithResult)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, actualWorkflow.Status())
+}
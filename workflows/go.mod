@@ -10,6 +10,7 @@ require (
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
 )
 
 require (
@@ -22,7 +23,14 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
-require github.com/gitlotto/common/database v0.0.0-00010101000000-000000000000
+require (
+	github.com/aws/aws-lambda-go v1.47.0
+	github.com/gitlotto/common/database v0.0.0-00010101000000-000000000000
+	github.com/gitlotto/common/queue v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.27.0
+)
+
+replace github.com/gitlotto/common/batcher => ../batcher
 
 replace github.com/gitlotto/common/database => ../database
 
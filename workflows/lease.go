@@ -0,0 +1,56 @@
+package workflows
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/gitlotto/common/zulu"
+)
+
+var ErrWorkflowIsClaimed = errors.New("workflow is claimed by another runner, postponed or finished")
+
+// Claim leases the workflow to the owner before its event is sent. The claim fails if the workflow has been
+// postponed or finished since it had been read or if another owner holds a lease that has not expired yet.
+// Postponing the workflow releases the lease.
+func (table WorkflowRecordTable) Claim(workflow WorkflowRecord, owner string, now time.Time, leaseFor time.Duration) (claimed WorkflowRecord, err error) {
+	output, err := table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(table.Table.Name),
+		Key: map[string]*dynamodb.AttributeValue{
+			"event_id": {
+				S: aws.String(workflow.EventId),
+			},
+			"target_queue_url": {
+				S: aws.String(workflow.TargetQueueUrl),
+			},
+		},
+		UpdateExpression: aws.String("SET lease_owner = :lease_owner, lease_expires_at = :lease_expires_at"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":lease_owner": {
+				S: aws.String(owner),
+			},
+			":lease_expires_at": {
+				S: aws.String(zulu.DateTimeFromTime(now.Add(leaseFor)).String()),
+			},
+			":now": {
+				S: aws.String(zulu.DateTimeFromTime(now).String()),
+			},
+			":start_at": {
+				S: aws.String(workflow.StartAt.String()),
+			},
+		},
+		ConditionExpression: aws.String("attribute_exists(is_open) AND start_at = :start_at AND (attribute_not_exists(lease_expires_at) OR lease_expires_at < :now)"),
+		ReturnValues:        aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if _, conditionalCheckFailed := err.(*dynamodb.ConditionalCheckFailedException); conditionalCheckFailed {
+		err = ErrWorkflowIsClaimed
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = dynamodbattribute.UnmarshalMap(output.Attributes, &claimed)
+	return
+}
@@ -0,0 +1,185 @@
+package workflows
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/gitlotto/common/database"
+)
+
+// WorkflowsOfKey reads the workflows of every target of the event of the given record of the given table.
+// For keys containing '#' or '\' it reads the workflows stored with their LegacyEventId as well.
+func (table WorkflowRecordTable) WorkflowsOfKey(tableName string, partitionKey string, sortKey *string) (workflowRecords []WorkflowRecord, err error) {
+	eventId := NewEventId(tableName, partitionKey, sortKey).String()
+	workflowRecords, err = table.WorkflowsOfEvent(eventId)
+	if err != nil {
+		return
+	}
+	legacyEventId := LegacyEventId(tableName, partitionKey, sortKey).String()
+	if legacyEventId == eventId {
+		return
+	}
+	legacyWorkflowRecords, err := table.WorkflowsOfEvent(legacyEventId)
+	if err != nil {
+		return
+	}
+	workflowRecords = append(workflowRecords, legacyWorkflowRecords...)
+	return
+}
+
+// TargetQueueIndex has target_queue_url as the partition key and workflow_state as the sort key.
+// Workflows stored before workflow_state had been introduced are not in the index until BackfillStates is run.
+type TargetQueueIndex struct {
+	TableName      string
+	IndexName      string
+	DynamodbClient *dynamodb.DynamoDB
+}
+
+// WorkflowsOfQueue reads the workflows of the target queue that are in the given state.
+func (index TargetQueueIndex) WorkflowsOfQueue(
+	targetQueueUrl string,
+	state WorkflowStatus,
+	limit int,
+	cursor *string,
+) (workflowRecords []WorkflowRecord, nextCursor *string, err error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              &index.TableName,
+		IndexName:              &index.IndexName,
+		KeyConditionExpression: aws.String("target_queue_url = :target_queue_url AND workflow_state = :workflow_state"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":target_queue_url": {
+				S: aws.String(targetQueueUrl),
+			},
+			":workflow_state": state.attributeValue(),
+		},
+		Limit: aws.Int64(int64(limit)),
+	}
+
+	if cursor != nil {
+		queryInput.ExclusiveStartKey, err = database.DecodeCursor(*cursor)
+		if err != nil {
+			return
+		}
+	}
+
+	items, err := index.DynamodbClient.Query(queryInput)
+	if err != nil {
+		return
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(items.Items, &workflowRecords)
+	if err != nil {
+		return
+	}
+
+	nextCursor, err = database.EncodeCursor(items.LastEvaluatedKey)
+	return
+}
+
+// statusAttributes are the attributes Status derives the status from, in the order it checks them.
+var statusAttributes = []struct {
+	name   string
+	status WorkflowStatus
+}{
+	{name: "is_open", status: StatusOpen},
+	{name: "is_failed", status: StatusFailed},
+	{name: "cancelled_at", status: StatusCancelled},
+	{name: "waiting_for", status: StatusWaiting},
+}
+
+// BackfillStates sets workflow_state of the workflows stored before it had been introduced, page by page until
+// there are no more of them or the deadline passes. A zero deadline means no deadline. The returned cursor resumes
+// the backfill, it is nil once every workflow has been visited.
+func (table WorkflowRecordTable) BackfillStates(pageSize int, cursor *string, deadline time.Time) (nextCursor *string, err error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(table.Table.Name),
+		FilterExpression: aws.String("attribute_not_exists(workflow_state)"),
+	}
+	if pageSize > 0 {
+		scanInput.Limit = aws.Int64(int64(pageSize))
+	}
+	if cursor != nil {
+		scanInput.ExclusiveStartKey, err = database.DecodeCursor(*cursor)
+		if err != nil {
+			return
+		}
+	}
+	nextCursor = cursor
+
+	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return
+		}
+
+		var page *dynamodb.ScanOutput
+		page, err = table.DynamodbClient.Scan(scanInput)
+		if err != nil {
+			return
+		}
+
+		for _, item := range page.Items {
+			var input *dynamodb.UpdateItemInput
+			input, err = table.stateBackfill(item)
+			if err != nil {
+				return
+			}
+			_, err = table.DynamodbClient.UpdateItem(input)
+			if _, conditionalCheckFailed := err.(*dynamodb.ConditionalCheckFailedException); conditionalCheckFailed {
+				// the workflow has moved on in the meantime and its transition has set the state already
+				err = nil
+			}
+			if err != nil {
+				return
+			}
+		}
+
+		nextCursor, err = database.EncodeCursor(page.LastEvaluatedKey)
+		if err != nil || nextCursor == nil {
+			return
+		}
+		scanInput.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+// stateBackfill only sets the state if it is still missing and the attributes Status has derived it from are unchanged,
+// so it never overwrites a concurrent transition.
+func (table WorkflowRecordTable) stateBackfill(item map[string]*dynamodb.AttributeValue) (input *dynamodb.UpdateItemInput, err error) {
+	attributes := map[string]*dynamodb.AttributeValue{}
+	for _, attribute := range statusAttributes {
+		if value, ok := item[attribute.name]; ok {
+			attributes[attribute.name] = value
+		}
+	}
+	var workflow WorkflowRecord
+	err = dynamodbattribute.UnmarshalMap(attributes, &workflow)
+	if err != nil {
+		return
+	}
+	status := workflow.Status()
+
+	conditions := []string{"attribute_not_exists(workflow_state)"}
+	for _, attribute := range statusAttributes {
+		if attribute.status == status {
+			conditions = append(conditions, "attribute_exists("+attribute.name+")")
+			break
+		}
+		conditions = append(conditions, "attribute_not_exists("+attribute.name+")")
+	}
+
+	input = &dynamodb.UpdateItemInput{
+		TableName: aws.String(table.Table.Name),
+		Key: map[string]*dynamodb.AttributeValue{
+			"event_id":         item["event_id"],
+			"target_queue_url": item["target_queue_url"],
+		},
+		UpdateExpression:    aws.String("SET workflow_state = :workflow_state"),
+		ConditionExpression: aws.String(strings.Join(conditions, " AND ")),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":workflow_state": status.attributeValue(),
+		},
+	}
+	return
+}
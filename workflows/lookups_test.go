@@ -0,0 +1,152 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/gitlotto/common/zulu"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WorkflowRecordTable_should_read_the_workflows_of_a_record_by_its_key(t *testing.T) {
+	var err error
+
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	tableName := uuid.New().String()
+	partitionKey := "partition#" + uuid.New().String()
+	targets := []FanOutTarget{
+		{TargetQueueUrl: uuid.New().String()},
+		{TargetQueueUrl: uuid.New().String()},
+	}
+	workflowRecords, err := NewFanOutWorkflowRecords(tableName, partitionKey, nil, createdAt, createdAt, targets, "event")
+	assert.NoError(t, err)
+	err = workflowRecordTable.FanOut(workflowRecords)
+	assert.NoError(t, err)
+
+	actualWorkflowRecords, err := workflowRecordTable.WorkflowsOfKey(tableName, partitionKey, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, workflowRecords, actualWorkflowRecords)
+
+	sortKey := "sort"
+	actualWorkflowRecords, err = workflowRecordTable.WorkflowsOfKey(tableName, partitionKey, &sortKey)
+	assert.NoError(t, err)
+	assert.Empty(t, actualWorkflowRecords)
+}
+
+func Test_WorkflowRecordTable_should_read_the_workflows_stored_before_the_escaping_of_the_key(t *testing.T) {
+	var err error
+
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	tableName := uuid.New().String()
+	partitionKey := "partition#" + uuid.New().String()
+
+	legacyWorkflow, err := NewStandardWorkflowRecord(tableName, partitionKey, nil, createdAt, createdAt, uuid.New().String(), "event")
+	assert.NoError(t, err)
+	legacyWorkflow.EventId = LegacyEventId(tableName, partitionKey, nil).String()
+	err = workflowRecordTable.Action(dynamodbClient).Persist(*legacyWorkflow)
+	assert.NoError(t, err)
+
+	workflow, err := NewStandardWorkflowRecord(tableName, partitionKey, nil, createdAt, createdAt, uuid.New().String(), "event")
+	assert.NoError(t, err)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(*workflow)
+	assert.NoError(t, err)
+
+	actualWorkflowRecords, err := workflowRecordTable.WorkflowsOfKey(tableName, partitionKey, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []WorkflowRecord{*legacyWorkflow, *workflow}, actualWorkflowRecords)
+}
+
+func Test_TargetQueueIndex_should_read_the_workflows_of_the_queue_by_state_page_by_page(t *testing.T) {
+	var err error
+
+	targetQueueUrl := uuid.New().String()
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+
+	expectedOpenWorkflows := []WorkflowRecord{}
+	for i := 0; i < 3; i++ {
+		var workflowRecord *WorkflowRecord
+		workflowRecord, err = NewStandardWorkflowRecord(uuid.New().String(), uuid.New().String(), nil, createdAt, createdAt, targetQueueUrl, "event")
+		assert.NoError(t, err)
+		err = workflowRecordTable.Action(dynamodbClient).Persist(*workflowRecord)
+		assert.NoError(t, err)
+		expectedOpenWorkflows = append(expectedOpenWorkflows, *workflowRecord)
+	}
+
+	closedWorkflow, err := NewStandardWorkflowRecord(uuid.New().String(), uuid.New().String(), nil, createdAt, createdAt, targetQueueUrl, "event")
+	assert.NoError(t, err)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(*closedWorkflow)
+	assert.NoError(t, err)
+	err = workflowRecordTable.Close(closedWorkflow.EventId, closedWorkflow.TargetQueueUrl, createdAt)
+	assert.NoError(t, err)
+
+	firstPage, cursor, err := targetQueueIndex.WorkflowsOfQueue(targetQueueUrl, StatusOpen, 2, nil)
+	assert.NoError(t, err)
+	assert.Len(t, firstPage, 2)
+	assert.NotNil(t, cursor)
+
+	secondPage, cursor, err := targetQueueIndex.WorkflowsOfQueue(targetQueueUrl, StatusOpen, 2, cursor)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expectedOpenWorkflows, append(firstPage, secondPage...))
+
+	finishedWorkflows, _, err := targetQueueIndex.WorkflowsOfQueue(targetQueueUrl, StatusFinished, 2, nil)
+	assert.NoError(t, err)
+	assert.Len(t, finishedWorkflows, 1)
+	assert.Equal(t, closedWorkflow.EventId, finishedWorkflows[0].EventId)
+}
+
+func Test_StateBackfill_should_only_set_the_state_if_its_attributes_are_unchanged(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	item, err := dynamodbattribute.MarshalMap(workflow)
+	assert.NoError(t, err)
+	delete(item, "workflow_state")
+
+	input, err := workflowRecordTable.stateBackfill(item)
+	assert.NoError(t, err)
+	assert.Equal(t, "SET workflow_state = :workflow_state", *input.UpdateExpression)
+	assert.Equal(t, "attribute_not_exists(workflow_state) AND attribute_exists(is_open)", *input.ConditionExpression)
+	assert.Equal(t, aws.String(string(StatusOpen)), input.ExpressionAttributeValues[":workflow_state"].S)
+	assert.Equal(t, item["event_id"], input.Key["event_id"])
+	assert.Equal(t, item["target_queue_url"], input.Key["target_queue_url"])
+
+	delete(item, "is_open")
+	item["cancelled_at"] = item["created_at"]
+	input, err = workflowRecordTable.stateBackfill(item)
+	assert.NoError(t, err)
+	assert.Equal(t, "attribute_not_exists(workflow_state) AND attribute_not_exists(is_open) AND attribute_not_exists(is_failed) AND attribute_exists(cancelled_at)", *input.ConditionExpression)
+	assert.Equal(t, aws.String(string(StatusCancelled)), input.ExpressionAttributeValues[":workflow_state"].S)
+
+	delete(item, "cancelled_at")
+	input, err = workflowRecordTable.stateBackfill(item)
+	assert.NoError(t, err)
+	assert.Equal(t, "attribute_not_exists(workflow_state) AND attribute_not_exists(is_open) AND attribute_not_exists(is_failed) AND attribute_not_exists(cancelled_at) AND attribute_not_exists(waiting_for)", *input.ConditionExpression)
+	assert.Equal(t, aws.String(string(StatusFinished)), input.ExpressionAttributeValues[":workflow_state"].S)
+}
+
+func Test_WorkflowRecordTable_should_backfill_the_state_of_workflows_stored_without_it(t *testing.T) {
+	var err error
+
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	workflowRecord, err := NewStandardWorkflowRecord(uuid.New().String(), uuid.New().String(), nil, createdAt, createdAt, uuid.New().String(), "event")
+	assert.NoError(t, err)
+	item, err := dynamodbattribute.MarshalMap(*workflowRecord)
+	assert.NoError(t, err)
+	delete(item, "workflow_state")
+	_, err = dynamodbClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(workflowRecordTable.Name),
+		Item:      item,
+	})
+	assert.NoError(t, err)
+
+	cursor, err := workflowRecordTable.BackfillStates(100, nil, time.Time{})
+	assert.NoError(t, err)
+	assert.Nil(t, cursor)
+
+	actualWorkflow := WorkflowRecord{EventId: workflowRecord.EventId, TargetQueueUrl: workflowRecord.TargetQueueUrl}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, actualWorkflow.State)
+}
@@ -1,26 +1,112 @@
 package workflows
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/zulu"
 )
 
 type OpenWorkflowsIndex struct {
 	TableName      string
 	IndexName      string
+	Shards         int
 	DynamodbClient *dynamodb.DynamoDB
 }
 
+// OpenWorkflowsBudget limits how many open workflows EachOpenWorkflow goes through.
+// A zero MaxWorkflows or a zero Deadline means no limit.
+type OpenWorkflowsBudget struct {
+	PageSize     int
+	MaxWorkflows int
+	Deadline     time.Time
+}
+
+func (budget OpenWorkflowsBudget) deadlinePassed() bool {
+	return !budget.Deadline.IsZero() && time.Now().After(budget.Deadline)
+}
+
+// exhausted tells whether EachOpenWorkflow could have stopped before the last open workflow.
+func (budget OpenWorkflowsBudget) exhausted(amountOfWorkflows int) bool {
+	return budget.deadlinePassed() || (budget.MaxWorkflows > 0 && amountOfWorkflows >= budget.MaxWorkflows)
+}
+
 func (index OpenWorkflowsIndex) OpenWorkflows(limit int, until zulu.DateTime) (workflowRecords []WorkflowRecord, err error) {
+	workflowRecords, _, err = index.OpenWorkflowsPage(limit, until, nil)
+	return
+}
+
+// OpenWorkflowsPage reads every is_open partition of the index and merges them by start_at.
+// The cursor keeps the position in each of the partitions.
+func (index OpenWorkflowsIndex) OpenWorkflowsPage(limit int, until zulu.DateTime, cursor *string) (workflowRecords []WorkflowRecord, nextCursor *string, err error) {
+	positions, err := decodeShardCursor(cursor)
+	if err != nil {
+		return
+	}
+
+	pages := []openWorkflowsShardPage{}
+	for _, partition := range index.partitions() {
+		position, known := positions[partition]
+		if known && position == nil {
+			continue
+		}
+		var page openWorkflowsShardPage
+		page, err = index.queryShard(partition, limit, until, position)
+		if err != nil {
+			return
+		}
+		pages = append(pages, page)
+	}
+
+	items, consumed := mergeShardPages(pages, limit)
+
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &workflowRecords)
+	if err != nil {
+		return
+	}
+
+	for i, page := range pages {
+		if consumed[i] == len(page.items) && page.exhausted {
+			positions[page.partition] = nil
+			continue
+		}
+		if consumed[i] == 0 {
+			continue
+		}
+		positions[page.partition], err = database.EncodeCursor(indexKeyOf(page.items[consumed[i]-1]))
+		if err != nil {
+			return
+		}
+	}
+
+	nextCursor, err = encodeShardCursor(positions, index.partitions())
+	return
+}
+
+func (index OpenWorkflowsIndex) partitions() []string {
+	partitions := []string{string(Open)}
+	if index.Shards < 2 {
+		return partitions
+	}
+	for shard := 0; shard < index.Shards; shard++ {
+		partitions = append(partitions, string(OpenShard(shard)))
+	}
+	return partitions
+}
+
+func (index OpenWorkflowsIndex) queryShard(partition string, limit int, until zulu.DateTime, position *string) (page openWorkflowsShardPage, err error) {
 	queryInput := &dynamodb.QueryInput{
 		TableName:              &index.TableName,
 		IndexName:              &index.IndexName,
 		KeyConditionExpression: aws.String("is_open = :is_open AND start_at <= :start_at"),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":is_open": {
-				S: aws.String(string(Open)),
+				S: aws.String(partition),
 			},
 			":start_at": {
 				S: aws.String(until.String()),
@@ -30,12 +116,140 @@ func (index OpenWorkflowsIndex) OpenWorkflows(limit int, until zulu.DateTime) (w
 		Limit:            aws.Int64(int64(limit)),
 	}
 
+	if position != nil {
+		queryInput.ExclusiveStartKey, err = database.DecodeCursor(*position)
+		if err != nil {
+			return
+		}
+	}
+
 	items, err := index.DynamodbClient.Query(queryInput)
 	if err != nil {
 		return
 	}
 
-	err = dynamodbattribute.UnmarshalListOfMaps(items.Items, &workflowRecords)
+	page = openWorkflowsShardPage{
+		partition: partition,
+		items:     items.Items,
+		exhausted: len(items.LastEvaluatedKey) == 0,
+	}
+	return
+}
+
+type openWorkflowsShardPage struct {
+	partition string
+	items     []map[string]*dynamodb.AttributeValue
+	exhausted bool
+}
+
+// mergeShardPages takes up to limit items from the pages, oldest start_at first, keeping the order within each page.
+// consumed tells how many items have been taken from each of the pages.
+func mergeShardPages(pages []openWorkflowsShardPage, limit int) (items []map[string]*dynamodb.AttributeValue, consumed []int) {
+	consumed = make([]int, len(pages))
+	for len(items) < limit {
+		oldest := -1
+		for i, page := range pages {
+			if consumed[i] == len(page.items) {
+				continue
+			}
+			if oldest == -1 || startAtOf(page.items[consumed[i]]) < startAtOf(pages[oldest].items[consumed[oldest]]) {
+				oldest = i
+			}
+		}
+		if oldest == -1 {
+			return
+		}
+		items = append(items, pages[oldest].items[consumed[oldest]])
+		consumed[oldest]++
+	}
+	return
+}
+
+func startAtOf(item map[string]*dynamodb.AttributeValue) string {
+	if startAt, ok := item["start_at"]; ok {
+		return aws.StringValue(startAt.S)
+	}
+	return ""
+}
+
+func indexKeyOf(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"event_id":         item["event_id"],
+		"target_queue_url": item["target_queue_url"],
+		"is_open":          item["is_open"],
+		"start_at":         item["start_at"],
+	}
+}
+
+// the shard cursor maps every partition to the cursor of its last read item, null marks an exhausted partition
+func decodeShardCursor(cursor *string) (positions map[string]*string, err error) {
+	positions = map[string]*string{}
+	if cursor == nil {
+		return
+	}
+	decodedCursor, err := base64.StdEncoding.DecodeString(*cursor)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(decodedCursor, &positions)
+	return
+}
 
+func encodeShardCursor(positions map[string]*string, partitions []string) (cursor *string, err error) {
+	exhausted := true
+	for _, partition := range partitions {
+		if position, known := positions[partition]; !known || position != nil {
+			exhausted = false
+		}
+	}
+	if exhausted {
+		return
+	}
+	cursorBytes, err := json.Marshal(positions)
+	if err != nil {
+		return
+	}
+	cursorCandidate := base64.StdEncoding.EncodeToString(cursorBytes)
+	cursor = &cursorCandidate
 	return
 }
+
+// EachOpenWorkflow hands the open workflows to the handler page by page, oldest first, until there are
+// no more of them, the budget is exhausted or the handler fails.
+func (index OpenWorkflowsIndex) EachOpenWorkflow(until zulu.DateTime, budget OpenWorkflowsBudget, handle func(workflowRecord WorkflowRecord) (err error)) (amountOfWorkflows int, err error) {
+	var cursor *string
+	for {
+		if budget.deadlinePassed() {
+			return
+		}
+
+		pageSize := budget.PageSize
+		if budget.MaxWorkflows > 0 && budget.MaxWorkflows-amountOfWorkflows < pageSize {
+			pageSize = budget.MaxWorkflows - amountOfWorkflows
+		}
+		if pageSize <= 0 {
+			return
+		}
+
+		var workflowRecords []WorkflowRecord
+		workflowRecords, cursor, err = index.OpenWorkflowsPage(pageSize, until, cursor)
+		if err != nil {
+			return
+		}
+
+		for _, workflowRecord := range workflowRecords {
+			if budget.deadlinePassed() {
+				return
+			}
+			err = handle(workflowRecord)
+			if err != nil {
+				return
+			}
+			amountOfWorkflows++
+		}
+
+		if cursor == nil {
+			return
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/gitlotto/common/zulu"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -79,6 +81,68 @@ func Test_OpenWorkflowsIndex_should_not_go_further_than_the_given_time(t *testin
 	assert.ElementsMatch(t, expectedOldestOpenWorkflows, actualOldestOpenWorkflows)
 }
 
+func Test_OpenWorkflowsIndex_should_merge_shards_by_start_at(t *testing.T) {
+	itemStartingAt := func(startAt string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"start_at": {S: aws.String(startAt)},
+		}
+	}
+	pages := []openWorkflowsShardPage{
+		{partition: "OPEN", items: []map[string]*dynamodb.AttributeValue{itemStartingAt("2023-09-18T00:00:00Z")}},
+		{partition: "OPEN#0", items: []map[string]*dynamodb.AttributeValue{itemStartingAt("2023-09-17T00:00:00Z"), itemStartingAt("2023-09-20T00:00:00Z")}},
+		{partition: "OPEN#1", items: []map[string]*dynamodb.AttributeValue{itemStartingAt("2023-09-19T00:00:00Z")}},
+	}
+
+	actualItems, actualConsumed := mergeShardPages(pages, 3)
+
+	expectedItems := []map[string]*dynamodb.AttributeValue{
+		itemStartingAt("2023-09-17T00:00:00Z"),
+		itemStartingAt("2023-09-18T00:00:00Z"),
+		itemStartingAt("2023-09-19T00:00:00Z"),
+	}
+	assert.Equal(t, expectedItems, actualItems)
+	assert.Equal(t, []int{1, 1, 1}, actualConsumed)
+}
+
+func Test_OpenWorkflowsIndex_should_read_all_shards_page_by_page(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	shardedOpenWorkflowsIndex := openWorkflowsIndex
+	shardedOpenWorkflowsIndex.Shards = 3
+
+	expectedOpenWorkflows := []WorkflowRecord{}
+	for day := 10; day <= 20; day++ {
+		openWorkflowRecord := makeWorkflowRecord(time.Date(2023, time.September, day, 12, 45, 14, 0, time.UTC))
+		if day%4 != 0 {
+			isOpen := OpenShardOf(openWorkflowRecord.EventId, shardedOpenWorkflowsIndex.Shards)
+			openWorkflowRecord.IsOpen = &isOpen
+		}
+		err = workflowRecordTable.Action(dynamodbClient).Persist(openWorkflowRecord)
+		assert.NoError(t, err)
+		expectedOpenWorkflows = append(expectedOpenWorkflows, openWorkflowRecord)
+	}
+
+	takeUntil := zulu.DateTimeFromTime(time.Date(2023, time.September, 22, 12, 45, 14, 0, time.UTC))
+
+	actualOpenWorkflows := []WorkflowRecord{}
+	var cursor *string
+	for pages := 0; pages < 10; pages++ {
+		var page []WorkflowRecord
+		page, cursor, err = shardedOpenWorkflowsIndex.OpenWorkflowsPage(4, takeUntil, cursor)
+		assert.NoError(t, err)
+		actualOpenWorkflows = append(actualOpenWorkflows, page...)
+		if cursor == nil {
+			break
+		}
+	}
+
+	assert.Nil(t, cursor)
+	assert.Equal(t, expectedOpenWorkflows, actualOpenWorkflows)
+}
+
 func makeWorkflowRecord(startAt time.Time) WorkflowRecord {
 	tableName := uuid.New().String()
 	partitionKey := uuid.New().String()
@@ -94,3 +158,70 @@ func makeWorkflowRecord(startAt time.Time) WorkflowRecord {
 	}
 	return *workflow
 }
+
+func Test_OpenWorkflowsIndex_should_read_open_workflows_page_by_page(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	expectedOpenWorkflows := []WorkflowRecord{}
+	for day := 17; day <= 21; day++ {
+		openWorkflowRecord := makeWorkflowRecord(time.Date(2023, time.September, day, 12, 45, 14, 0, time.UTC))
+		err = workflowRecordTable.Action(dynamodbClient).Persist(openWorkflowRecord)
+		assert.NoError(t, err)
+		expectedOpenWorkflows = append(expectedOpenWorkflows, openWorkflowRecord)
+	}
+
+	takeUntil := zulu.DateTimeFromTime(time.Date(2023, time.September, 22, 12, 45, 14, 0, time.UTC))
+
+	actualFirstPage, cursor, err := openWorkflowsIndex.OpenWorkflowsPage(3, takeUntil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOpenWorkflows[:3], actualFirstPage)
+	assert.NotNil(t, cursor)
+
+	actualSecondPage, cursor, err := openWorkflowsIndex.OpenWorkflowsPage(3, takeUntil, cursor)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedOpenWorkflows[3:], actualSecondPage)
+	assert.Nil(t, cursor)
+}
+
+func Test_OpenWorkflowsIndex_should_go_through_open_workflows_until_the_budget_is_exhausted(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	expectedOpenWorkflows := []WorkflowRecord{}
+	for day := 17; day <= 21; day++ {
+		openWorkflowRecord := makeWorkflowRecord(time.Date(2023, time.September, day, 12, 45, 14, 0, time.UTC))
+		err = workflowRecordTable.Action(dynamodbClient).Persist(openWorkflowRecord)
+		assert.NoError(t, err)
+		expectedOpenWorkflows = append(expectedOpenWorkflows, openWorkflowRecord)
+	}
+
+	takeUntil := zulu.DateTimeFromTime(time.Date(2023, time.September, 22, 12, 45, 14, 0, time.UTC))
+
+	actualOpenWorkflows := []WorkflowRecord{}
+	collect := func(workflowRecord WorkflowRecord) error {
+		actualOpenWorkflows = append(actualOpenWorkflows, workflowRecord)
+		return nil
+	}
+
+	amountOfWorkflows, err := openWorkflowsIndex.EachOpenWorkflow(takeUntil, OpenWorkflowsBudget{PageSize: 2, MaxWorkflows: 4}, collect)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, amountOfWorkflows)
+	assert.Equal(t, expectedOpenWorkflows[:4], actualOpenWorkflows)
+
+	actualOpenWorkflows = []WorkflowRecord{}
+	amountOfWorkflows, err = openWorkflowsIndex.EachOpenWorkflow(takeUntil, OpenWorkflowsBudget{PageSize: 2}, collect)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, amountOfWorkflows)
+	assert.Equal(t, expectedOpenWorkflows, actualOpenWorkflows)
+
+	actualOpenWorkflows = []WorkflowRecord{}
+	amountOfWorkflows, err = openWorkflowsIndex.EachOpenWorkflow(takeUntil, OpenWorkflowsBudget{PageSize: 2, Deadline: time.Now().Add(-time.Second)}, collect)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, amountOfWorkflows)
+	assert.Empty(t, actualOpenWorkflows)
+}
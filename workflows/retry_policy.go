@@ -0,0 +1,57 @@
+package workflows
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// maxRetryDelay caps the delay of policies without MaxDelay, so the exponential growth never overflows time.Duration.
+const maxRetryDelay = time.Hour * 24 * 365
+
+// RetryPolicy computes the delay before the next start of a workflow from the amount of its previous starts.
+// A zero MaxAttempts means the workflow is retried until it is closed.
+type RetryPolicy struct {
+	InitialDelay time.Duration `dynamodbav:"initial_delay"`
+	Multiplier   float64       `dynamodbav:"multiplier"`
+	MaxDelay     time.Duration `dynamodbav:"max_delay"`
+	Jitter       float64       `dynamodbav:"jitter"`
+	MaxAttempts  int           `dynamodbav:"max_attempts"`
+}
+
+// NextDelay is InitialDelay * Multiplier^amountOfStarts capped by MaxDelay, or by a year without it,
+// randomly spread by the Jitter fraction of it.
+func (policy RetryPolicy) NextDelay(amountOfStarts int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	maxDelay := policy.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = maxRetryDelay
+	}
+	delay := float64(policy.InitialDelay) * math.Pow(multiplier, float64(amountOfStarts))
+	if math.IsNaN(delay) || delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	switch {
+	case delay < 0:
+		delay = 0
+	case delay >= math.MaxInt64:
+		delay = math.MaxInt64
+	}
+	return time.Duration(delay)
+}
+
+func (policy RetryPolicy) Exhausted(amountOfStarts int) bool {
+	return policy.MaxAttempts > 0 && amountOfStarts >= policy.MaxAttempts
+}
+
+func WithRetryPolicy(policy RetryPolicy) WorkflowOption {
+	return func(workflowRecord *WorkflowRecord) {
+		workflowRecord.RetryPolicy = &policy
+	}
+}
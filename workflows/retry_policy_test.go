@@ -0,0 +1,68 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RetryPolicy_should_grow_the_delay_exponentially_up_to_the_cap(t *testing.T) {
+	policy := RetryPolicy{
+		InitialDelay: time.Minute,
+		Multiplier:   2,
+		MaxDelay:     time.Minute * 10,
+	}
+
+	assert.Equal(t, time.Minute, policy.NextDelay(0))
+	assert.Equal(t, time.Minute*2, policy.NextDelay(1))
+	assert.Equal(t, time.Minute*8, policy.NextDelay(3))
+	assert.Equal(t, time.Minute*10, policy.NextDelay(4))
+	assert.Equal(t, time.Minute*10, policy.NextDelay(40))
+}
+
+func Test_RetryPolicy_should_not_overflow_without_a_cap(t *testing.T) {
+	policy := RetryPolicy{
+		InitialDelay: time.Minute,
+		Multiplier:   2,
+	}
+
+	assert.Equal(t, time.Hour*24*365, policy.NextDelay(64))
+	assert.Equal(t, time.Hour*24*365, policy.NextDelay(100000))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		assert.WithinRange(t, time.Time{}.Add(policy.NextDelay(100000)), time.Time{}.Add(time.Hour*24*365/2), time.Time{}.Add(time.Hour*24*365*3/2))
+	}
+}
+
+func Test_RetryPolicy_should_spread_the_delay_by_the_jitter(t *testing.T) {
+	policy := RetryPolicy{
+		InitialDelay: time.Minute,
+		Multiplier:   2,
+		Jitter:       0.25,
+	}
+
+	for i := 0; i < 100; i++ {
+		assert.WithinRange(t, time.Time{}.Add(policy.NextDelay(2)), time.Time{}.Add(time.Minute*3), time.Time{}.Add(time.Minute*5))
+	}
+}
+
+func Test_RetryPolicy_should_be_exhausted_after_max_attempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	assert.False(t, policy.Exhausted(2))
+	assert.True(t, policy.Exhausted(3))
+
+	assert.False(t, RetryPolicy{}.Exhausted(1000))
+}
+
+func Test_RetryPolicy_should_be_stored_on_the_workflow(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.September, 17, 12, 45, 14, 0, time.UTC))
+	assert.Equal(t, time.Minute*10, workflow.NextStartIn(time.Minute*10))
+	assert.False(t, workflow.AttemptsExhausted())
+
+	WithRetryPolicy(RetryPolicy{InitialDelay: time.Second, Multiplier: 3, MaxAttempts: 2})(&workflow)
+	workflow.AmountOfStarts = 2
+	assert.Equal(t, time.Second*9, workflow.NextStartIn(time.Minute*10))
+	assert.True(t, workflow.AttemptsExhausted())
+}
@@ -0,0 +1,294 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/gitlotto/common/database"
+	"github.com/gitlotto/common/zulu"
+)
+
+var ErrSagaWithoutSteps = errors.New("saga should have at least one step")
+
+func ErrSagaStepWithoutAction(stepName string) error {
+	return fmt.Errorf("saga step %s should have an action queue", stepName)
+}
+
+func ErrDuplicatedSagaQueue(queueUrl string) error {
+	return fmt.Errorf("saga uses the queue %s more than once", queueUrl)
+}
+
+func ErrNotAStepOfSaga(sagaName string) error {
+	return fmt.Errorf("workflow is not a step of the saga %s", sagaName)
+}
+
+// SagaStep delivers the event to the action queue. If a later step fails, the event is delivered to the
+// compensation queue to undo the action. Steps without a compensation queue have nothing to undo.
+type SagaStep struct {
+	Name                 string
+	ActionQueueUrl       string
+	CompensationQueueUrl string
+}
+
+// Saga runs its steps one after another, every step is a workflow of the same event. When a step fails,
+// the completed steps are compensated in reverse order, again one after another. All the compensations are
+// persisted together with the failure, the ones after the first are waiting for the compensation before them.
+type Saga struct {
+	Name  string
+	Steps []SagaStep
+}
+
+// SagaPosition tells which step of which saga the workflow is.
+type SagaPosition struct {
+	Saga         string `dynamodbav:"saga"`
+	Step         int    `dynamodbav:"step"`
+	Compensating bool   `dynamodbav:"compensating,omitempty"`
+}
+
+// NewSaga checks that every queue is used once, since the queue is the sort key of the workflows of the event.
+func NewSaga(name string, steps ...SagaStep) (saga Saga, err error) {
+	if len(steps) == 0 {
+		err = ErrSagaWithoutSteps
+		return
+	}
+	seenQueues := map[string]bool{}
+	for _, step := range steps {
+		if step.ActionQueueUrl == "" {
+			err = ErrSagaStepWithoutAction(step.Name)
+			return
+		}
+		for _, queueUrl := range []string{step.ActionQueueUrl, step.CompensationQueueUrl} {
+			if queueUrl == "" {
+				continue
+			}
+			if seenQueues[queueUrl] {
+				err = ErrDuplicatedSagaQueue(queueUrl)
+				return
+			}
+			seenQueues[queueUrl] = true
+		}
+	}
+	saga = Saga{Name: name, Steps: steps}
+	return
+}
+
+// Start creates the workflow of the first step. The message group id is used by every fifo queue of the saga.
+func (saga Saga) Start(
+	tableName string,
+	partitionKey string,
+	sortKey *string,
+	createdAt zulu.DateTime,
+	startAt zulu.DateTime,
+	event string,
+	eventGroupId string,
+	options ...WorkflowOption,
+) (workflowRecord *WorkflowRecord, err error) {
+	actionQueueUrl := saga.Steps[0].ActionQueueUrl
+	if strings.HasSuffix(actionQueueUrl, ".fifo") {
+		workflowRecord, err = NewFifoWorkflowRecord(tableName, partitionKey, sortKey, createdAt, startAt, actionQueueUrl, event, eventGroupId, options...)
+	} else {
+		workflowRecord, err = NewStandardWorkflowRecord(tableName, partitionKey, sortKey, createdAt, startAt, actionQueueUrl, event, options...)
+	}
+	if err != nil {
+		return
+	}
+	workflowRecord.EventMessageGroupId = eventGroupId
+	workflowRecord.SagaPosition = &SagaPosition{Saga: saga.Name, Step: 0}
+	return
+}
+
+// FollowUpOf is the workflow to run after the given one is finished: the next action, or the next
+// compensation while compensating. It is nil when the saga is over.
+func (saga Saga) FollowUpOf(workflowRecord WorkflowRecord, startAt zulu.DateTime) (followUp *WorkflowRecord, err error) {
+	position, err := saga.positionOf(workflowRecord)
+	if err != nil {
+		return
+	}
+	if position.Compensating {
+		return saga.compensationBefore(workflowRecord, position.Step, startAt)
+	}
+	if position.Step+1 == len(saga.Steps) {
+		return
+	}
+	return saga.stepWorkflow(workflowRecord, SagaPosition{Saga: saga.Name, Step: position.Step + 1}, startAt)
+}
+
+// CompensationsOf are the compensations to run when the given action fails, in the order to run them.
+// There are none when none of the completed steps has a compensation queue or when a compensation itself fails.
+func (saga Saga) CompensationsOf(workflowRecord WorkflowRecord, startAt zulu.DateTime) (compensations []WorkflowRecord, err error) {
+	position, err := saga.positionOf(workflowRecord)
+	if err != nil || position.Compensating {
+		return
+	}
+	for step := position.Step; ; {
+		var compensation *WorkflowRecord
+		compensation, err = saga.compensationBefore(workflowRecord, step, startAt)
+		if err != nil || compensation == nil {
+			return
+		}
+		compensations = append(compensations, *compensation)
+		step = compensation.SagaPosition.Step
+	}
+}
+
+func (saga Saga) positionOf(workflowRecord WorkflowRecord) (position SagaPosition, err error) {
+	if workflowRecord.SagaPosition == nil ||
+		workflowRecord.SagaPosition.Saga != saga.Name ||
+		workflowRecord.SagaPosition.Step < 0 ||
+		workflowRecord.SagaPosition.Step >= len(saga.Steps) {
+		err = ErrNotAStepOfSaga(saga.Name)
+		return
+	}
+	position = *workflowRecord.SagaPosition
+	return
+}
+
+func (saga Saga) compensationBefore(workflowRecord WorkflowRecord, step int, startAt zulu.DateTime) (compensation *WorkflowRecord, err error) {
+	for previous := step - 1; previous >= 0; previous-- {
+		if saga.Steps[previous].CompensationQueueUrl == "" {
+			continue
+		}
+		return saga.stepWorkflow(workflowRecord, SagaPosition{Saga: saga.Name, Step: previous, Compensating: true}, startAt)
+	}
+	return
+}
+
+// stepWorkflow derives the workflow of the step from the current one, so the event, the message group, the target type,
+// the retry policy, the deduplication and the open shard stay the same for the whole saga. The step is validated
+// like the workflows created by the constructors.
+func (saga Saga) stepWorkflow(current WorkflowRecord, position SagaPosition, startAt zulu.DateTime) (step *WorkflowRecord, err error) {
+	targetQueueUrl := saga.Steps[position.Step].ActionQueueUrl
+	if position.Compensating {
+		targetQueueUrl = saga.Steps[position.Step].CompensationQueueUrl
+	}
+	isOpen := current.OpenShard()
+	if current.OpenShards == 0 && current.IsOpen != nil {
+		isOpen = *current.IsOpen
+	}
+	queueKind := StandardQueue
+	if strings.HasSuffix(targetQueueUrl, ".fifo") {
+		queueKind = FifoQueue
+	}
+	step = &WorkflowRecord{
+		EventId:             current.EventId,
+		TargetQueueUrl:      targetQueueUrl,
+		CreatedAt:           startAt,
+		StartAt:             startAt,
+		AmountOfStarts:      0,
+		IsOpen:              &isOpen,
+		Event:               current.Event,
+		EventMessageGroupId: current.EventMessageGroupId,
+		QueueKind:           queueKind,
+		TargetType:          current.TargetType,
+		RetryPolicy:         current.RetryPolicy,
+		Deduplication:       current.Deduplication,
+		OpenShards:          current.OpenShards,
+		SagaPosition:        &position,
+	}
+	step.State = step.Status()
+	err = step.validate()
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// TransactCompleteSagaStep includes closing the step with its result and scheduling whatever follows it into the transaction.
+// The compensation following a completed compensation exists already and is opened instead of being created.
+func (table WorkflowRecordTable) TransactCompleteSagaStep(
+	transaction *database.Transaction,
+	saga Saga,
+	workflowRecord WorkflowRecord,
+	finishedAt zulu.DateTime,
+	result string,
+) *database.Transaction {
+	followUp, err := saga.FollowUpOf(workflowRecord, finishedAt)
+	if err != nil {
+		return transaction.Include(nil, err)
+	}
+	if followUp == nil {
+		return transaction.Include(table.TransactionalCloseWithResult(workflowRecord.EventId, workflowRecord.TargetQueueUrl, finishedAt, result))
+	}
+	if followUp.SagaPosition.Compensating {
+		return transaction.
+			Include(table.TransactionalCloseWithResult(workflowRecord.EventId, workflowRecord.TargetQueueUrl, finishedAt, result)).
+			Include(table.transactionalOpenWaiting(*followUp, workflowRecord.TargetQueueUrl))
+	}
+	return table.TransactCloseAndFollowUp(transaction, workflowRecord.EventId, workflowRecord.TargetQueueUrl, finishedAt, result, *followUp)
+}
+
+func (table WorkflowRecordTable) CompleteSagaStep(saga Saga, workflowRecord WorkflowRecord, finishedAt zulu.DateTime, result string) (err error) {
+	return table.
+		TransactCompleteSagaStep(database.NewTransaction(), saga, workflowRecord, finishedAt, result).
+		Execute(table.DynamodbClient)
+}
+
+// TransactFailSagaStep includes failing the step and creating the compensations of the completed steps into
+// the transaction. The first compensation is open, every other one waits for the compensation before it.
+// When a compensation fails, the ones after it keep waiting until it is redriven and completed.
+func (table WorkflowRecordTable) TransactFailSagaStep(
+	transaction *database.Transaction,
+	saga Saga,
+	workflowRecord WorkflowRecord,
+	failedAt zulu.DateTime,
+	reason string,
+) *database.Transaction {
+	compensations, err := saga.CompensationsOf(workflowRecord, failedAt)
+	if err != nil {
+		return transaction.Include(nil, err)
+	}
+	transaction.Include(table.TransactionalFail(workflowRecord.EventId, workflowRecord.TargetQueueUrl, failedAt, reason))
+	predecessor := workflowRecord
+	for i, compensation := range compensations {
+		compensation.ParentEventId = aws.String(predecessor.EventId)
+		compensation.ParentTargetQueueUrl = aws.String(predecessor.TargetQueueUrl)
+		if i > 0 {
+			compensation.IsOpen = nil
+			compensation.WaitingFor = aws.String(predecessor.TargetQueueUrl)
+			compensation.State = compensation.Status()
+		}
+		transaction.Include(table.TransactInsert(compensation))
+		predecessor = compensation
+	}
+	return transaction
+}
+
+func (table WorkflowRecordTable) FailSagaStep(saga Saga, workflowRecord WorkflowRecord, failedAt zulu.DateTime, reason string) (err error) {
+	return table.
+		TransactFailSagaStep(database.NewTransaction(), saga, workflowRecord, failedAt, reason).
+		Execute(table.DynamodbClient)
+}
+
+// transactionalOpenWaiting opens the workflow waiting for the given predecessor at the start of the given one.
+func (table WorkflowRecordTable) transactionalOpenWaiting(workflow WorkflowRecord, predecessorTargetQueueUrl string) (item *dynamodb.TransactWriteItem, err error) {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(table.Table.Name),
+			Key: map[string]*dynamodb.AttributeValue{
+				"event_id": {
+					S: aws.String(workflow.EventId),
+				},
+				"target_queue_url": {
+					S: aws.String(workflow.TargetQueueUrl),
+				},
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":is_open": {
+					S: aws.String(string(*workflow.IsOpen)),
+				},
+				":start_at": {
+					S: aws.String(workflow.StartAt.String()),
+				},
+				":workflow_state": StatusOpen.attributeValue(),
+				":waiting_for": {
+					S: aws.String(predecessorTargetQueueUrl),
+				},
+			},
+			UpdateExpression:    aws.String("SET is_open = :is_open, start_at = :start_at, workflow_state = :workflow_state REMOVE waiting_for"),
+			ConditionExpression: aws.String("waiting_for = :waiting_for"),
+		},
+	}, nil
+}
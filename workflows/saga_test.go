@@ -0,0 +1,262 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gitlotto/common/zulu"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeLotterySaga() Saga {
+	saga, err := NewSaga(
+		"lottery",
+		SagaStep{Name: "reserve ticket", ActionQueueUrl: uuid.New().String() + ".fifo", CompensationQueueUrl: uuid.New().String() + ".fifo"},
+		SagaStep{Name: "charge", ActionQueueUrl: uuid.New().String(), CompensationQueueUrl: uuid.New().String()},
+		SagaStep{Name: "confirm", ActionQueueUrl: uuid.New().String() + ".fifo"},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return saga
+}
+
+func Test_Saga_should_use_every_queue_once(t *testing.T) {
+	_, err := NewSaga("lottery")
+	assert.Equal(t, ErrSagaWithoutSteps, err)
+
+	_, err = NewSaga("lottery", SagaStep{Name: "reserve ticket"})
+	assert.Equal(t, ErrSagaStepWithoutAction("reserve ticket"), err)
+
+	_, err = NewSaga(
+		"lottery",
+		SagaStep{Name: "reserve ticket", ActionQueueUrl: "tickets", CompensationQueueUrl: "refunds"},
+		SagaStep{Name: "charge", ActionQueueUrl: "refunds"},
+	)
+	assert.Equal(t, ErrDuplicatedSagaQueue("refunds"), err)
+}
+
+func Test_Saga_should_follow_the_steps_up_one_after_another(t *testing.T) {
+	saga := makeLotterySaga()
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+
+	reserve, err := saga.Start("table", "partition", nil, createdAt, createdAt, "event", "group", WithShards(4))
+	assert.NoError(t, err)
+	assert.Equal(t, saga.Steps[0].ActionQueueUrl, reserve.TargetQueueUrl)
+	assert.Equal(t, &SagaPosition{Saga: "lottery", Step: 0}, reserve.SagaPosition)
+
+	charge, err := saga.FollowUpOf(*reserve, finishedAt)
+	assert.NoError(t, err)
+	expectedCharge := &WorkflowRecord{
+		EventId:             reserve.EventId,
+		TargetQueueUrl:      saga.Steps[1].ActionQueueUrl,
+		CreatedAt:           finishedAt,
+		StartAt:             finishedAt,
+		IsOpen:              reserve.IsOpen,
+		Event:               "event",
+		EventMessageGroupId: "group",
+		QueueKind:           StandardQueue,
+		State:               StatusOpen,
+		OpenShards:          4,
+		SagaPosition:        &SagaPosition{Saga: "lottery", Step: 1},
+	}
+	assert.Equal(t, expectedCharge, charge)
+
+	finishedReserve := *reserve
+	finishedReserve.IsOpen = nil
+	chargeOfFinished, err := saga.FollowUpOf(finishedReserve, finishedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, reserve.IsOpen, chargeOfFinished.IsOpen)
+
+	confirm, err := saga.FollowUpOf(*charge, finishedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, saga.Steps[2].ActionQueueUrl, confirm.TargetQueueUrl)
+	assert.Equal(t, FifoQueue, confirm.QueueKind)
+
+	followUp, err := saga.FollowUpOf(*confirm, finishedAt)
+	assert.NoError(t, err)
+	assert.Nil(t, followUp)
+}
+
+func Test_Saga_should_keep_the_target_type_and_validate_every_step(t *testing.T) {
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	saga, err := NewSaga(
+		"notifications",
+		SagaStep{Name: "announce", ActionQueueUrl: "arn:aws:sns:us-east-1:000000000000:announcements"},
+		SagaStep{Name: "confirm", ActionQueueUrl: "arn:aws:sns:us-east-1:000000000000:confirmations.fifo"},
+	)
+	assert.NoError(t, err)
+
+	announce, err := saga.Start("table", "partition", nil, createdAt, createdAt, "event", "group", WithTargetType(SnsTarget))
+	assert.NoError(t, err)
+
+	confirm, err := saga.FollowUpOf(*announce, createdAt)
+	assert.NoError(t, err)
+	assert.Equal(t, SnsTarget, confirm.TargetType)
+	assert.Equal(t, FifoQueue, confirm.QueueKind)
+
+	strategy := DeduplicationStrategy(customDeduplicationPrefix + "unknown")
+	announce, err = saga.Start("table", "partition", nil, createdAt, createdAt, "event", "group", WithTargetType(SnsTarget), WithDeduplication(strategy))
+	assert.NoError(t, err)
+
+	_, err = saga.FollowUpOf(*announce, createdAt)
+	assert.Equal(t, ErrUnregisteredDeduplication(strategy), err)
+}
+
+func Test_Saga_should_compensate_the_completed_steps_in_reverse_order(t *testing.T) {
+	saga := makeLotterySaga()
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	failedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+
+	reserve, err := saga.Start("table", "partition", nil, createdAt, createdAt, "event", "group")
+	assert.NoError(t, err)
+	charge, err := saga.FollowUpOf(*reserve, createdAt)
+	assert.NoError(t, err)
+	confirm, err := saga.FollowUpOf(*charge, createdAt)
+	assert.NoError(t, err)
+
+	compensations, err := saga.CompensationsOf(*confirm, failedAt)
+	assert.NoError(t, err)
+	assert.Len(t, compensations, 2)
+	refund, release := compensations[0], compensations[1]
+	assert.Equal(t, saga.Steps[1].CompensationQueueUrl, refund.TargetQueueUrl)
+	assert.Equal(t, &SagaPosition{Saga: "lottery", Step: 1, Compensating: true}, refund.SagaPosition)
+	assert.Equal(t, saga.Steps[0].CompensationQueueUrl, release.TargetQueueUrl)
+	assert.Equal(t, &SagaPosition{Saga: "lottery", Step: 0, Compensating: true}, release.SagaPosition)
+
+	releaseFollowingRefund, err := saga.FollowUpOf(refund, failedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, &release, releaseFollowingRefund)
+
+	followUp, err := saga.FollowUpOf(release, failedAt)
+	assert.NoError(t, err)
+	assert.Nil(t, followUp)
+
+	compensations, err = saga.CompensationsOf(refund, failedAt)
+	assert.NoError(t, err)
+	assert.Empty(t, compensations)
+
+	compensations, err = saga.CompensationsOf(*reserve, failedAt)
+	assert.NoError(t, err)
+	assert.Empty(t, compensations)
+}
+
+func Test_Saga_should_not_follow_up_a_workflow_of_another_saga(t *testing.T) {
+	saga := makeLotterySaga()
+
+	_, err := saga.FollowUpOf(makeWorkflowRecord(time.Now()), zulu.DateTimeFromTime(time.Now()))
+	assert.Equal(t, ErrNotAStepOfSaga("lottery"), err)
+}
+
+func Test_WorkflowRecordTable_should_fail_the_saga_step_and_schedule_the_compensation(t *testing.T) {
+	var err error
+
+	saga := makeLotterySaga()
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	failedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+
+	reserve, err := saga.Start(uuid.New().String(), uuid.New().String(), nil, createdAt, createdAt, "event", uuid.New().String())
+	assert.NoError(t, err)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(*reserve)
+	assert.NoError(t, err)
+
+	err = workflowRecordTable.CompleteSagaStep(saga, *reserve, finishedAt, "reserved")
+	assert.NoError(t, err)
+
+	charge := WorkflowRecord{
+		EventId:        reserve.EventId,
+		TargetQueueUrl: saga.Steps[1].ActionQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&charge)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, charge.Status())
+
+	err = workflowRecordTable.FailSagaStep(saga, charge, failedAt, "card declined")
+	assert.NoError(t, err)
+
+	statuses, err := workflowRecordTable.StatusesOfEvent(reserve.EventId)
+	assert.NoError(t, err)
+	expectedStatuses := map[string]WorkflowStatus{
+		saga.Steps[0].ActionQueueUrl:       StatusFinished,
+		saga.Steps[1].ActionQueueUrl:       StatusFailed,
+		saga.Steps[0].CompensationQueueUrl: StatusOpen,
+	}
+	assert.Equal(t, expectedStatuses, statuses)
+
+	release := WorkflowRecord{
+		EventId:        reserve.EventId,
+		TargetQueueUrl: saga.Steps[0].CompensationQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&release)
+	assert.NoError(t, err)
+	assert.Equal(t, &SagaPosition{Saga: "lottery", Step: 0, Compensating: true}, release.SagaPosition)
+	assert.Equal(t, aws.String(charge.TargetQueueUrl), release.ParentTargetQueueUrl)
+}
+
+func Test_WorkflowRecordTable_should_keep_the_compensations_after_a_failed_compensation_waiting(t *testing.T) {
+	var err error
+
+	saga, err := NewSaga(
+		"lottery",
+		SagaStep{Name: "reserve ticket", ActionQueueUrl: uuid.New().String(), CompensationQueueUrl: uuid.New().String()},
+		SagaStep{Name: "charge", ActionQueueUrl: uuid.New().String(), CompensationQueueUrl: uuid.New().String()},
+		SagaStep{Name: "confirm", ActionQueueUrl: uuid.New().String(), CompensationQueueUrl: uuid.New().String()},
+	)
+	assert.NoError(t, err)
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	failedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	redrivenAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	refundedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 18, 12, 45, 14, 0, time.UTC))
+
+	reserve, err := saga.Start(uuid.New().String(), uuid.New().String(), nil, createdAt, createdAt, "event", uuid.New().String())
+	assert.NoError(t, err)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(*reserve)
+	assert.NoError(t, err)
+	err = workflowRecordTable.CompleteSagaStep(saga, *reserve, createdAt, "reserved")
+	assert.NoError(t, err)
+
+	readStep := func(targetQueueUrl string) (workflow WorkflowRecord) {
+		workflow = WorkflowRecord{EventId: reserve.EventId, TargetQueueUrl: targetQueueUrl}
+		err := workflowRecordTable.Action(dynamodbClient).Reconstitute(&workflow)
+		assert.NoError(t, err)
+		return
+	}
+
+	err = workflowRecordTable.CompleteSagaStep(saga, readStep(saga.Steps[1].ActionQueueUrl), createdAt, "charged")
+	assert.NoError(t, err)
+	err = workflowRecordTable.FailSagaStep(saga, readStep(saga.Steps[2].ActionQueueUrl), failedAt, "out of tickets")
+	assert.NoError(t, err)
+
+	statuses, err := workflowRecordTable.StatusesOfEvent(reserve.EventId)
+	assert.NoError(t, err)
+	expectedStatuses := map[string]WorkflowStatus{
+		saga.Steps[0].ActionQueueUrl:       StatusFinished,
+		saga.Steps[1].ActionQueueUrl:       StatusFinished,
+		saga.Steps[2].ActionQueueUrl:       StatusFailed,
+		saga.Steps[1].CompensationQueueUrl: StatusOpen,
+		saga.Steps[0].CompensationQueueUrl: StatusWaiting,
+	}
+	assert.Equal(t, expectedStatuses, statuses)
+
+	// the middle compensation fails, the first step is still to be compensated
+	err = workflowRecordTable.FailSagaStep(saga, readStep(saga.Steps[1].CompensationQueueUrl), failedAt, "payment provider is down")
+	assert.NoError(t, err)
+	release := readStep(saga.Steps[0].CompensationQueueUrl)
+	assert.Equal(t, StatusWaiting, release.Status())
+	assert.Equal(t, aws.String(saga.Steps[1].CompensationQueueUrl), release.WaitingFor)
+
+	err = workflowRecordTable.Redrive(readStep(saga.Steps[1].CompensationQueueUrl), redrivenAt)
+	assert.NoError(t, err)
+	err = workflowRecordTable.CompleteSagaStep(saga, readStep(saga.Steps[1].CompensationQueueUrl), refundedAt, "refunded")
+	assert.NoError(t, err)
+
+	release = readStep(saga.Steps[0].CompensationQueueUrl)
+	assert.Equal(t, StatusOpen, release.Status())
+	assert.Equal(t, StatusOpen, release.State)
+	assert.Equal(t, refundedAt, release.StartAt)
+	assert.Nil(t, release.WaitingFor)
+}
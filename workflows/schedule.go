@@ -0,0 +1,175 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrEmptySchedule = errors.New("schedule should have either an interval or a cron expression")
+var ErrNoNextOccurrence = errors.New("schedule has no occurrence in the next five years")
+
+func ErrInvalidCronExpression(expression string, reason string) error {
+	return fmt.Errorf("invalid cron expression %q: %s", expression, reason)
+}
+
+// Schedule makes a workflow recurring. Either Interval or Cron is set. The cron expression has the five
+// fields minute, hour, day of month, month and day of week and is evaluated in UTC.
+type Schedule struct {
+	Interval time.Duration `dynamodbav:"interval,omitempty"`
+	Cron     string        `dynamodbav:"cron,omitempty"`
+}
+
+func NewIntervalSchedule(interval time.Duration) (schedule Schedule, err error) {
+	if interval <= 0 {
+		err = ErrEmptySchedule
+		return
+	}
+	schedule = Schedule{Interval: interval}
+	return
+}
+
+func NewCronSchedule(expression string) (schedule Schedule, err error) {
+	_, err = parseCron(expression)
+	if err != nil {
+		return
+	}
+	schedule = Schedule{Cron: expression}
+	return
+}
+
+// Next is the first occurrence of the schedule after the given time.
+func (schedule Schedule) Next(after time.Time) (next time.Time, err error) {
+	after = after.UTC()
+	if schedule.Cron == "" {
+		if schedule.Interval <= 0 {
+			err = ErrEmptySchedule
+			return
+		}
+		next = after.Add(schedule.Interval)
+		return
+	}
+	expression, err := parseCron(schedule.Cron)
+	if err != nil {
+		return
+	}
+	next, err = expression.next(after)
+	return
+}
+
+func WithSchedule(schedule Schedule) WorkflowOption {
+	return func(workflowRecord *WorkflowRecord) {
+		workflowRecord.Schedule = &schedule
+	}
+}
+
+type cronExpression struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	anyDay      bool
+	anyWeekday  bool
+}
+
+func parseCron(expression string) (cron cronExpression, err error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		err = ErrInvalidCronExpression(expression, "it should have 5 fields")
+		return
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&cron.minutes, &cron.hours, &cron.daysOfMonth, &cron.months, &cron.daysOfWeek}
+	for i, field := range fields {
+		*sets[i], err = parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			err = ErrInvalidCronExpression(expression, err.Error())
+			return
+		}
+	}
+	if cron.daysOfWeek&(1<<7) != 0 {
+		cron.daysOfWeek |= 1
+	}
+	cron.anyDay = strings.HasPrefix(fields[2], "*")
+	cron.anyWeekday = strings.HasPrefix(fields[4], "*")
+	return
+}
+
+func parseCronField(field string, min int, max int) (set uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		from, to := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			fromPart, toPart, _ := strings.Cut(rangePart, "-")
+			from, err = strconv.Atoi(fromPart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+			to, err = strconv.Atoi(toPart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			from, err = strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if hasStep {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+
+		for value := from; value <= to; value += step {
+			set |= 1 << uint(value)
+		}
+	}
+	return
+}
+
+func (cron cronExpression) next(after time.Time) (next time.Time, err error) {
+	next = after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		switch {
+		case cron.months&(1<<uint(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !cron.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+		case cron.hours&(1<<uint(next.Hour())) == 0:
+			next = next.Truncate(time.Hour).Add(time.Hour)
+		case cron.minutes&(1<<uint(next.Minute())) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return
+		}
+	}
+	err = ErrNoNextOccurrence
+	return
+}
+
+// matchesDay follows cron: when both day fields are restricted, either of them is enough.
+func (cron cronExpression) matchesDay(t time.Time) bool {
+	dayOfMonth := cron.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := cron.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if cron.anyDay || cron.anyWeekday {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
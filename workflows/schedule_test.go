@@ -0,0 +1,72 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Schedule_should_add_the_interval(t *testing.T) {
+	schedule, err := NewIntervalSchedule(time.Hour * 6)
+	assert.NoError(t, err)
+
+	actualNext, err := schedule.Next(time.Date(2023, time.October, 16, 22, 45, 14, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, time.October, 17, 4, 45, 14, 0, time.UTC), actualNext)
+
+	_, err = NewIntervalSchedule(0)
+	assert.Equal(t, ErrEmptySchedule, err)
+}
+
+func Test_Schedule_should_find_the_next_cron_occurrence_in_utc(t *testing.T) {
+	after := time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC) // Monday
+
+	testCases := []struct {
+		cron         string
+		expectedNext time.Time
+	}{
+		{"* * * * *", time.Date(2023, time.October, 16, 12, 46, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, time.October, 16, 13, 0, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2023, time.October, 17, 9, 30, 0, 0, time.UTC)},
+		{"0 8-10,14 * * *", time.Date(2023, time.October, 16, 14, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 5", time.Date(2023, time.October, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2023, time.October, 22, 12, 0, 0, 0, time.UTC)},
+		{"0 12 31 * 5", time.Date(2023, time.October, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, testCase := range testCases {
+		schedule, err := NewCronSchedule(testCase.cron)
+		assert.NoError(t, err, testCase.cron)
+
+		actualNext, err := schedule.Next(after)
+		assert.NoError(t, err, testCase.cron)
+		assert.Equal(t, testCase.expectedNext, actualNext, testCase.cron)
+	}
+}
+
+func Test_Schedule_should_reject_invalid_cron_expressions(t *testing.T) {
+	for _, cron := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-2 * * * *"} {
+		_, err := NewCronSchedule(cron)
+		assert.Error(t, err, cron)
+	}
+
+	_, err := Schedule{Cron: "0 0 31 2 *"}.Next(time.Now())
+	assert.Equal(t, ErrNoNextOccurrence, err)
+}
+
+func Test_Schedule_should_give_every_occurrence_its_own_deduplication_id(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	firstDeduplicationId := deduplicationIdOf(t, workflow)
+
+	workflow.Occurrence = 1
+	secondDeduplicationId := deduplicationIdOf(t, workflow)
+
+	workflow.Occurrence = 2
+	thirdDeduplicationId := deduplicationIdOf(t, workflow)
+
+	assert.NotEqual(t, firstDeduplicationId, secondDeduplicationId)
+	assert.NotEqual(t, secondDeduplicationId, thirdDeduplicationId)
+}
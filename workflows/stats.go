@@ -0,0 +1,120 @@
+package workflows
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gitlotto/common/zulu"
+)
+
+// staleWorkflowsSample limits how many of the stale workflows are kept in the stats.
+const staleWorkflowsSample = 10
+
+var endOfTime = zulu.DateTimeFromTime(time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC))
+
+// OpenWorkflowsStats describes the backlog of the outboxer. Lag is how long the oldest due workflow has been
+// waiting for its start. A workflow is stale when it has been waiting for longer than the staleness threshold,
+// which means nobody outboxes it. The stats are partial when the budget ran out before the last open workflow,
+// the lag stays exact since the oldest workflows come first, the amounts are only lower bounds.
+type OpenWorkflowsStats struct {
+	AmountOfOpenWorkflows      int
+	OldestStartAt              *zulu.DateTime
+	Lag                        time.Duration
+	AmountOfStartsDistribution map[int]int
+	AmountOfStaleWorkflows     int
+	StaleWorkflows             []WorkflowRecord
+	IsPartial                  bool
+}
+
+// OpenWorkflowsThresholds are the limits of the stats to alert on. A zero threshold is not checked.
+type OpenWorkflowsThresholds struct {
+	MaxOpenWorkflows  int
+	MaxLag            time.Duration
+	MaxStaleWorkflows int
+	MaxAmountOfStarts int
+}
+
+// Stats goes through the open workflows within the budget, including the ones that are not due yet.
+func (index OpenWorkflowsIndex) Stats(now time.Time, staleAfter time.Duration, budget OpenWorkflowsBudget) (stats OpenWorkflowsStats, err error) {
+	stats = OpenWorkflowsStats{
+		AmountOfStartsDistribution: map[int]int{},
+	}
+	amountOfWorkflows, err := index.EachOpenWorkflow(endOfTime, budget, func(workflowRecord WorkflowRecord) (err error) {
+		stats.add(workflowRecord, now, staleAfter)
+		return
+	})
+	if err != nil {
+		return
+	}
+	stats.IsPartial = budget.exhausted(amountOfWorkflows)
+	return
+}
+
+func (stats *OpenWorkflowsStats) add(workflowRecord WorkflowRecord, now time.Time, staleAfter time.Duration) {
+	stats.AmountOfOpenWorkflows++
+	stats.AmountOfStartsDistribution[workflowRecord.AmountOfStarts]++
+
+	if stats.OldestStartAt == nil || workflowRecord.StartAt.ToTime().Before(stats.OldestStartAt.ToTime()) {
+		startAt := workflowRecord.StartAt
+		stats.OldestStartAt = &startAt
+	}
+
+	waitingFor := now.Sub(workflowRecord.StartAt.ToTime())
+	if waitingFor > stats.Lag {
+		stats.Lag = waitingFor
+	}
+	if waitingFor > staleAfter {
+		stats.AmountOfStaleWorkflows++
+		if len(stats.StaleWorkflows) < staleWorkflowsSample {
+			stats.StaleWorkflows = append(stats.StaleWorkflows, workflowRecord)
+		}
+	}
+}
+
+// Breaches lists the thresholds exceeded by the stats in a human readable form.
+func (stats OpenWorkflowsStats) Breaches(thresholds OpenWorkflowsThresholds) (breaches []string) {
+	if thresholds.MaxOpenWorkflows > 0 && stats.AmountOfOpenWorkflows > thresholds.MaxOpenWorkflows {
+		breaches = append(breaches, fmt.Sprintf("%d open workflows exceed %d", stats.AmountOfOpenWorkflows, thresholds.MaxOpenWorkflows))
+	}
+	if thresholds.MaxLag > 0 && stats.Lag > thresholds.MaxLag {
+		breaches = append(breaches, fmt.Sprintf("lag of %s exceeds %s", stats.Lag, thresholds.MaxLag))
+	}
+	if thresholds.MaxStaleWorkflows > 0 && stats.AmountOfStaleWorkflows > thresholds.MaxStaleWorkflows {
+		breaches = append(breaches, fmt.Sprintf("%d stale workflows exceed %d", stats.AmountOfStaleWorkflows, thresholds.MaxStaleWorkflows))
+	}
+	if thresholds.MaxAmountOfStarts > 0 {
+		amountOfRestartedWorkflows := 0
+		for amountOfStarts, amountOfWorkflows := range stats.AmountOfStartsDistribution {
+			if amountOfStarts > thresholds.MaxAmountOfStarts {
+				amountOfRestartedWorkflows += amountOfWorkflows
+			}
+		}
+		if amountOfRestartedWorkflows > 0 {
+			breaches = append(breaches, fmt.Sprintf("%d workflows have been started more than %d times", amountOfRestartedWorkflows, thresholds.MaxAmountOfStarts))
+		}
+	}
+	return
+}
+
+// String is meant for logs and notifications, the distribution is ordered by the amount of starts.
+func (stats OpenWorkflowsStats) String() string {
+	amountsOfStarts := make([]int, 0, len(stats.AmountOfStartsDistribution))
+	for amountOfStarts := range stats.AmountOfStartsDistribution {
+		amountsOfStarts = append(amountsOfStarts, amountOfStarts)
+	}
+	sort.Ints(amountsOfStarts)
+	distribution := make([]string, 0, len(amountsOfStarts))
+	for _, amountOfStarts := range amountsOfStarts {
+		distribution = append(distribution, fmt.Sprintf("%d:%d", amountOfStarts, stats.AmountOfStartsDistribution[amountOfStarts]))
+	}
+	description := fmt.Sprintf(
+		"open=%d lag=%s stale=%d starts=[%s]",
+		stats.AmountOfOpenWorkflows, stats.Lag, stats.AmountOfStaleWorkflows, strings.Join(distribution, " "),
+	)
+	if stats.IsPartial {
+		description += " partial"
+	}
+	return description
+}
@@ -0,0 +1,100 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gitlotto/common/zulu"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OpenWorkflowsStats_should_describe_the_backlog(t *testing.T) {
+	now := time.Date(2023, time.October, 16, 12, 0, 0, 0, time.UTC)
+	stats := OpenWorkflowsStats{AmountOfStartsDistribution: map[int]int{}}
+
+	staleWorkflow := makeWorkflowRecord(now.Add(-time.Hour * 3))
+	staleWorkflow.AmountOfStarts = 5
+	dueWorkflow := makeWorkflowRecord(now.Add(-time.Minute))
+	futureWorkflow := makeWorkflowRecord(now.Add(time.Hour))
+
+	for _, workflowRecord := range []WorkflowRecord{staleWorkflow, dueWorkflow, futureWorkflow} {
+		stats.add(workflowRecord, now, time.Hour)
+	}
+
+	oldestStartAt := zulu.DateTimeFromTime(now.Add(-time.Hour * 3))
+	expectedStats := OpenWorkflowsStats{
+		AmountOfOpenWorkflows:      3,
+		OldestStartAt:              &oldestStartAt,
+		Lag:                        time.Hour * 3,
+		AmountOfStartsDistribution: map[int]int{0: 2, 5: 1},
+		AmountOfStaleWorkflows:     1,
+		StaleWorkflows:             []WorkflowRecord{staleWorkflow},
+	}
+	assert.Equal(t, expectedStats, stats)
+	assert.Equal(t, "open=3 lag=3h0m0s stale=1 starts=[0:2 5:1]", stats.String())
+}
+
+func Test_OpenWorkflowsStats_should_be_partial_if_the_budget_is_exhausted(t *testing.T) {
+	assert.False(t, OpenWorkflowsBudget{PageSize: 2}.exhausted(100))
+	assert.False(t, OpenWorkflowsBudget{PageSize: 2, MaxWorkflows: 4}.exhausted(3))
+	assert.True(t, OpenWorkflowsBudget{PageSize: 2, MaxWorkflows: 4}.exhausted(4))
+	assert.True(t, OpenWorkflowsBudget{PageSize: 2, Deadline: time.Now().Add(-time.Second)}.exhausted(0))
+
+	stats := OpenWorkflowsStats{AmountOfOpenWorkflows: 4, AmountOfStartsDistribution: map[int]int{0: 4}, IsPartial: true}
+	assert.Equal(t, "open=4 lag=0s stale=0 starts=[0:4] partial", stats.String())
+}
+
+func Test_OpenWorkflowsStats_should_report_the_breached_thresholds(t *testing.T) {
+	stats := OpenWorkflowsStats{
+		AmountOfOpenWorkflows:      3,
+		Lag:                        time.Hour * 3,
+		AmountOfStartsDistribution: map[int]int{0: 2, 5: 1},
+		AmountOfStaleWorkflows:     1,
+	}
+
+	assert.Empty(t, stats.Breaches(OpenWorkflowsThresholds{}))
+
+	thresholds := OpenWorkflowsThresholds{
+		MaxOpenWorkflows:  2,
+		MaxLag:            time.Hour,
+		MaxStaleWorkflows: 1,
+		MaxAmountOfStarts: 3,
+	}
+	expectedBreaches := []string{
+		"3 open workflows exceed 2",
+		"lag of 3h0m0s exceeds 1h0m0s",
+		"1 workflows have been started more than 3 times",
+	}
+	assert.Equal(t, expectedBreaches, stats.Breaches(thresholds))
+}
+
+func Test_OpenWorkflowsIndex_should_collect_stats_of_every_open_workflow(t *testing.T) {
+	var err error
+
+	err = deleteAllWorkflows()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	for _, startAt := range []time.Time{now.Add(-time.Hour * 2), now.Add(-time.Minute), now.Add(time.Hour)} {
+		err = workflowRecordTable.Action(dynamodbClient).Persist(makeWorkflowRecord(startAt))
+		assert.NoError(t, err)
+	}
+	closedWorkflow := makeWorkflowRecord(now.Add(-time.Hour * 5))
+	closedWorkflow.IsOpen = nil
+	err = workflowRecordTable.Action(dynamodbClient).Persist(closedWorkflow)
+	assert.NoError(t, err)
+
+	stats, err := openWorkflowsIndex.Stats(now, time.Hour, OpenWorkflowsBudget{PageSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.AmountOfOpenWorkflows)
+	assert.Equal(t, 1, stats.AmountOfStaleWorkflows)
+	assert.Equal(t, map[int]int{0: 3}, stats.AmountOfStartsDistribution)
+	assert.Equal(t, zulu.DateTimeFromTime(now.Add(-time.Hour*2)), *stats.OldestStartAt)
+	assert.False(t, stats.IsPartial)
+
+	stats, err = openWorkflowsIndex.Stats(now, time.Hour, OpenWorkflowsBudget{PageSize: 2, MaxWorkflows: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.AmountOfOpenWorkflows)
+	assert.Equal(t, zulu.DateTimeFromTime(now.Add(-time.Hour*2)), *stats.OldestStartAt)
+	assert.True(t, stats.IsPartial)
+}
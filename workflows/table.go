@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -15,6 +16,7 @@ type WorkflowRecordTable struct {
 }
 
 var ErrWorkflowHadBeenFinished = errors.New("workflow had been finished")
+var ErrWorkflowIsNotFailed = errors.New("workflow is not failed")
 
 func (table WorkflowRecordTable) Postpone(workflow WorkflowRecord, nextStartAt zulu.DateTime) (err error) {
 	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
@@ -27,7 +29,7 @@ func (table WorkflowRecordTable) Postpone(workflow WorkflowRecord, nextStartAt z
 				S: aws.String(workflow.TargetQueueUrl),
 			},
 		},
-		UpdateExpression: aws.String("SET start_at = :start_at ADD amount_of_starts :by_one"),
+		UpdateExpression: aws.String("SET start_at = :start_at ADD amount_of_starts :by_one REMOVE lease_owner, lease_expires_at"),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":start_at": {
 				S: aws.String(nextStartAt.String()),
@@ -38,26 +40,61 @@ func (table WorkflowRecordTable) Postpone(workflow WorkflowRecord, nextStartAt z
 		},
 		ConditionExpression: aws.String("attribute_exists(is_open)"),
 	})
-	switch errRefined := err.(type) {
-	case *dynamodb.ConditionalCheckFailedException:
-		return ErrWorkflowHadBeenFinished
-	case *dynamodb.TransactionCanceledException:
-		for _, reason := range errRefined.CancellationReasons {
-			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
-				return ErrWorkflowHadBeenFinished
-			}
-		}
-	default:
+	return refineConditionalCheckFailure(err)
+}
+
+// TransactionalClose reads the workflow to close it like Close does, a recurring workflow is rescheduled instead.
+// It closes whichever occurrence is current, so on recurring workflows it cannot tell a stale duplicate from
+// a real close: prefer TransactionalCloseOccurrence with the occurrence carried by the message.
+func (table WorkflowRecordTable) TransactionalClose(
+	worflowEventId string,
+	workflowTargetQueueUrl string,
+	finishedAt zulu.DateTime,
+) (item *dynamodb.TransactWriteItem, err error) {
+	workflow := WorkflowRecord{
+		EventId:        worflowEventId,
+		TargetQueueUrl: workflowTargetQueueUrl,
+	}
+	err = table.Action(table.DynamodbClient).Reconstitute(&workflow)
+	if errors.Is(err, database.ErrNotFound) {
+		// the condition of closing fails for a missing workflow as well
+		return table.transactionalCloseOccurrence(worflowEventId, workflowTargetQueueUrl, finishedAt), nil
+	}
+	if err != nil {
 		return
 	}
-	return err
+	return table.TransactionalFinish(workflow, finishedAt)
 }
 
-func (table WorkflowRecordTable) TransactionalClose(
+// TransactionalCloseOccurrence closes the given occurrence of the workflow. A duplicate close of an occurrence
+// that has been rescheduled already fails with ErrWorkflowHadBeenFinished instead of finishing the next one.
+func (table WorkflowRecordTable) TransactionalCloseOccurrence(
 	worflowEventId string,
 	workflowTargetQueueUrl string,
+	occurrence int,
 	finishedAt zulu.DateTime,
 ) (item *dynamodb.TransactWriteItem, err error) {
+	workflow := WorkflowRecord{
+		EventId:        worflowEventId,
+		TargetQueueUrl: workflowTargetQueueUrl,
+	}
+	err = table.Action(table.DynamodbClient).Reconstitute(&workflow)
+	if errors.Is(err, database.ErrNotFound) {
+		return table.transactionalCloseOccurrence(worflowEventId, workflowTargetQueueUrl, finishedAt), nil
+	}
+	if err != nil {
+		return
+	}
+	// the reschedule is conditioned on the occurrence of the caller, not on the one that has just been read
+	workflow.Occurrence = occurrence
+	return table.TransactionalFinish(workflow, finishedAt)
+}
+
+func (table WorkflowRecordTable) transactionalCloseOccurrence(
+	worflowEventId string,
+	workflowTargetQueueUrl string,
+	finishedAt zulu.DateTime,
+) *dynamodb.TransactWriteItem {
 	return &dynamodb.TransactWriteItem{
 		Update: &dynamodb.Update{
 			TableName: aws.String(table.Table.Name),
@@ -73,32 +110,258 @@ func (table WorkflowRecordTable) TransactionalClose(
 				":finished_at": {
 					S: aws.String(finishedAt.String()),
 				},
+				":workflow_state": StatusFinished.attributeValue(),
 			},
-			UpdateExpression:    aws.String("SET finished_at = :finished_at REMOVE is_open"),
+			UpdateExpression:    aws.String("SET finished_at = :finished_at, workflow_state = :workflow_state REMOVE is_open, lease_owner, lease_expires_at"),
 			ConditionExpression: aws.String("attribute_exists(is_open)"),
 		},
-	}, nil
+	}
 }
 
+// Close finishes the workflow. A recurring workflow stays open and is rescheduled to its next occurrence instead.
+// Like TransactionalClose, it cannot tell a stale duplicate from a real close on recurring workflows.
 func (table WorkflowRecordTable) Close(worflowEventId string, workflowTargetQueueUrl string, finishedAt zulu.DateTime) (err error) {
+	item, err := table.TransactionalClose(worflowEventId, workflowTargetQueueUrl, finishedAt)
+	if err != nil {
+		return
+	}
+	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 item.Update.TableName,
+		Key:                       item.Update.Key,
+		ExpressionAttributeValues: item.Update.ExpressionAttributeValues,
+		UpdateExpression:          item.Update.UpdateExpression,
+		ConditionExpression:       item.Update.ConditionExpression,
+	})
+	return refineConditionalCheckFailure(err)
+}
+
+// CloseOccurrence is Close of the given occurrence of the workflow.
+func (table WorkflowRecordTable) CloseOccurrence(worflowEventId string, workflowTargetQueueUrl string, occurrence int, finishedAt zulu.DateTime) (err error) {
+	item, err := table.TransactionalCloseOccurrence(worflowEventId, workflowTargetQueueUrl, occurrence, finishedAt)
+	if err != nil {
+		return
+	}
+	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 item.Update.TableName,
+		Key:                       item.Update.Key,
+		ExpressionAttributeValues: item.Update.ExpressionAttributeValues,
+		UpdateExpression:          item.Update.UpdateExpression,
+		ConditionExpression:       item.Update.ConditionExpression,
+	})
+	return refineConditionalCheckFailure(err)
+}
+
+// TransactionalFinish closes the workflow or reschedules it if it is a recurring one.
+func (table WorkflowRecordTable) TransactionalFinish(workflow WorkflowRecord, finishedAt zulu.DateTime) (item *dynamodb.TransactWriteItem, err error) {
+	if workflow.Schedule != nil {
+		return table.TransactionalReschedule(workflow, finishedAt)
+	}
+	return table.transactionalCloseOccurrence(workflow.EventId, workflow.TargetQueueUrl, finishedAt), nil
+}
+
+// Finish is Close for a workflow that has already been read.
+func (table WorkflowRecordTable) Finish(workflow WorkflowRecord, finishedAt zulu.DateTime) (err error) {
+	item, err := table.TransactionalFinish(workflow, finishedAt)
+	if err != nil {
+		return
+	}
+	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 item.Update.TableName,
+		Key:                       item.Update.Key,
+		ExpressionAttributeValues: item.Update.ExpressionAttributeValues,
+		UpdateExpression:          item.Update.UpdateExpression,
+		ConditionExpression:       item.Update.ConditionExpression,
+	})
+	return refineConditionalCheckFailure(err)
+}
+
+// TransactionalReschedule finishes the current occurrence of the recurring workflow and opens the next one
+// with reset attempts and without a lease. It fails if another occurrence has been finished in the meantime.
+func (table WorkflowRecordTable) TransactionalReschedule(workflow WorkflowRecord, finishedAt zulu.DateTime) (item *dynamodb.TransactWriteItem, err error) {
+	if workflow.Schedule == nil {
+		return nil, ErrEmptySchedule
+	}
+	nextStartAt, err := workflow.Schedule.Next(finishedAt.ToTime())
+	if err != nil {
+		return
+	}
+
+	conditionExpression := "attribute_exists(is_open) AND attribute_not_exists(occurrence)"
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+		":start_at": {
+			S: aws.String(zulu.DateTimeFromTime(nextStartAt).String()),
+		},
+		":last_finished_at": {
+			S: aws.String(finishedAt.String()),
+		},
+		":zero": {
+			N: aws.String("0"),
+		},
+		":by_one": {
+			N: aws.String("1"),
+		},
+	}
+	if workflow.Occurrence > 0 {
+		conditionExpression = "attribute_exists(is_open) AND occurrence = :occurrence"
+		expressionAttributeValues[":occurrence"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.Itoa(workflow.Occurrence)),
+		}
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(table.Table.Name),
+			Key: map[string]*dynamodb.AttributeValue{
+				"event_id": {
+					S: aws.String(workflow.EventId),
+				},
+				"target_queue_url": {
+					S: aws.String(workflow.TargetQueueUrl),
+				},
+			},
+			ExpressionAttributeValues: expressionAttributeValues,
+			UpdateExpression:          aws.String("SET start_at = :start_at, last_finished_at = :last_finished_at, amount_of_starts = :zero ADD occurrence :by_one REMOVE lease_owner, lease_expires_at"),
+			ConditionExpression:       aws.String(conditionExpression),
+		},
+	}, nil
+}
+
+func (table WorkflowRecordTable) TransactionalCancel(
+	worflowEventId string,
+	workflowTargetQueueUrl string,
+	cancelledAt zulu.DateTime,
+	reason string,
+) (item *dynamodb.TransactWriteItem, err error) {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(table.Table.Name),
+			Key: map[string]*dynamodb.AttributeValue{
+				"event_id": {
+					S: aws.String(worflowEventId),
+				},
+				"target_queue_url": {
+					S: aws.String(workflowTargetQueueUrl),
+				},
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":cancelled_at": {
+					S: aws.String(cancelledAt.String()),
+				},
+				":cancellation_reason": {
+					S: aws.String(reason),
+				},
+				":workflow_state": StatusCancelled.attributeValue(),
+			},
+			UpdateExpression:    aws.String("SET cancelled_at = :cancelled_at, cancellation_reason = :cancellation_reason, workflow_state = :workflow_state REMOVE is_open, lease_owner, lease_expires_at"),
+			ConditionExpression: aws.String("attribute_exists(is_open)"),
+		},
+	}, nil
+}
+
+// Cancel aborts the workflow before it is finished. Unlike Close it keeps the reason and does not set finished_at.
+func (table WorkflowRecordTable) Cancel(worflowEventId string, workflowTargetQueueUrl string, cancelledAt zulu.DateTime, reason string) (err error) {
+	item, err := table.TransactionalCancel(worflowEventId, workflowTargetQueueUrl, cancelledAt, reason)
+	if err != nil {
+		return
+	}
+	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 item.Update.TableName,
+		Key:                       item.Update.Key,
+		ExpressionAttributeValues: item.Update.ExpressionAttributeValues,
+		UpdateExpression:          item.Update.UpdateExpression,
+		ConditionExpression:       item.Update.ConditionExpression,
+	})
+	return refineConditionalCheckFailure(err)
+}
+
+func (table WorkflowRecordTable) TransactionalFail(
+	worflowEventId string,
+	workflowTargetQueueUrl string,
+	failedAt zulu.DateTime,
+	reason string,
+) (item *dynamodb.TransactWriteItem, err error) {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(table.Table.Name),
+			Key: map[string]*dynamodb.AttributeValue{
+				"event_id": {
+					S: aws.String(worflowEventId),
+				},
+				"target_queue_url": {
+					S: aws.String(workflowTargetQueueUrl),
+				},
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":failed_at": {
+					S: aws.String(failedAt.String()),
+				},
+				":failure_reason": {
+					S: aws.String(reason),
+				},
+				":is_failed": {
+					S: aws.String(string(Failed)),
+				},
+				":workflow_state": StatusFailed.attributeValue(),
+			},
+			UpdateExpression:    aws.String("SET is_failed = :is_failed, failed_at = :failed_at, failure_reason = :failure_reason, workflow_state = :workflow_state REMOVE is_open, lease_owner, lease_expires_at"),
+			ConditionExpression: aws.String("attribute_exists(is_open)"),
+		},
+	}, nil
+}
+
+// Fail stops retrying the workflow and keeps the reason of the failure.
+func (table WorkflowRecordTable) Fail(workflow WorkflowRecord, failedAt zulu.DateTime, reason string) (err error) {
+	item, err := table.TransactionalFail(workflow.EventId, workflow.TargetQueueUrl, failedAt, reason)
+	if err != nil {
+		return
+	}
+	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 item.Update.TableName,
+		Key:                       item.Update.Key,
+		ExpressionAttributeValues: item.Update.ExpressionAttributeValues,
+		UpdateExpression:          item.Update.UpdateExpression,
+		ConditionExpression:       item.Update.ConditionExpression,
+	})
+	return refineConditionalCheckFailure(err)
+}
+
+// Redrive reopens the failed workflow with reset attempts in the shard given by the amount of shards stored on it.
+func (table WorkflowRecordTable) Redrive(workflow WorkflowRecord, startAt zulu.DateTime) (err error) {
 	_, err = table.DynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
 		TableName: aws.String(table.Table.Name),
 		Key: map[string]*dynamodb.AttributeValue{
 			"event_id": {
-				S: aws.String(worflowEventId),
+				S: aws.String(workflow.EventId),
 			},
 			"target_queue_url": {
-				S: aws.String(workflowTargetQueueUrl),
+				S: aws.String(workflow.TargetQueueUrl),
 			},
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":finished_at": {
-				S: aws.String(finishedAt.String()),
+			":is_open": {
+				S: aws.String(string(workflow.OpenShard())),
+			},
+			":start_at": {
+				S: aws.String(startAt.String()),
 			},
+			":zero": {
+				N: aws.String("0"),
+			},
+			":by_one": {
+				N: aws.String("1"),
+			},
+			":workflow_state": StatusOpen.attributeValue(),
 		},
-		UpdateExpression:    aws.String("SET finished_at = :finished_at REMOVE is_open"),
-		ConditionExpression: aws.String("attribute_exists(is_open)"),
+		UpdateExpression:    aws.String("SET is_open = :is_open, start_at = :start_at, amount_of_starts = :zero, workflow_state = :workflow_state ADD amount_of_redrives :by_one REMOVE is_failed, failed_at, failure_reason"),
+		ConditionExpression: aws.String("attribute_exists(is_failed)"),
 	})
+	if _, conditionalCheckFailed := err.(*dynamodb.ConditionalCheckFailedException); conditionalCheckFailed {
+		return ErrWorkflowIsNotFailed
+	}
+	return
+}
+
+func refineConditionalCheckFailure(err error) error {
 	switch errRefined := err.(type) {
 	case *dynamodb.ConditionalCheckFailedException:
 		return ErrWorkflowHadBeenFinished
@@ -108,8 +371,6 @@ func (table WorkflowRecordTable) Close(worflowEventId string, workflowTargetQueu
 				return ErrWorkflowHadBeenFinished
 			}
 		}
-	default:
-		return
 	}
 	return err
 }
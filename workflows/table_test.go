@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/zulu"
 	"github.com/google/uuid"
@@ -126,6 +127,7 @@ func Test_WorkflowRecordTable_should_close_the_workflow_if_it_is_still_open(t *t
 	expectedWorkflow := *workflow
 	expectedWorkflow.FinishedAt = &finishedAt
 	expectedWorkflow.IsOpen = nil
+	expectedWorkflow.State = StatusFinished
 
 	assert.Equal(t, expectedWorkflow, actualWorkflow)
 }
@@ -209,6 +211,7 @@ func Test_WorkflowRecordTable_should_close_the_workflow_in_a_transaction_if_it_i
 	expectedWorkflow := *workflow
 	expectedWorkflow.FinishedAt = &finishedAt
 	expectedWorkflow.IsOpen = nil
+	expectedWorkflow.State = StatusFinished
 
 	assert.Equal(t, expectedWorkflow, actualWorkflow)
 }
@@ -318,3 +321,305 @@ func Test_WorkflowRecordTable_should_not_create_a_workflow_if_it_has_not_been_cr
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, database.ErrConditionalCheckFailed)
 }
+
+func Test_WorkflowRecordTable_should_fail_the_workflow_if_it_is_still_open(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	WithRetryPolicy(RetryPolicy{InitialDelay: time.Minute, Multiplier: 2, MaxAttempts: 3})(&workflow)
+
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	failedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+
+	err = workflowRecordTable.Fail(workflow, failedAt, "exhausted 3 attempts")
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+
+	expectedWorkflow := workflow
+	isFailed := Failed
+	expectedWorkflow.IsOpen = nil
+	expectedWorkflow.IsFailed = &isFailed
+	expectedWorkflow.FailedAt = &failedAt
+	expectedWorkflow.FailureReason = aws.String("exhausted 3 attempts")
+	expectedWorkflow.State = StatusFailed
+
+	assert.Equal(t, expectedWorkflow, actualWorkflow)
+
+	err = workflowRecordTable.Fail(workflow, failedAt, "exhausted 3 attempts")
+	assert.Equal(t, ErrWorkflowHadBeenFinished, err)
+}
+
+func Test_WorkflowRecordTable_should_redrive_the_workflow_if_it_had_failed(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	workflow.AmountOfStarts = 3
+	WithShards(4)(&workflow)
+
+	err = workflowRecordTable.Redrive(workflow, workflow.StartAt)
+	assert.Equal(t, ErrWorkflowIsNotFailed, err)
+
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	err = workflowRecordTable.Redrive(workflow, workflow.StartAt)
+	assert.Equal(t, ErrWorkflowIsNotFailed, err)
+
+	failedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Fail(workflow, failedAt, "exhausted 3 attempts")
+	assert.NoError(t, err)
+
+	redrivenAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 18, 12, 45, 14, 0, time.UTC))
+	failedWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&failedWorkflow)
+	assert.NoError(t, err)
+
+	err = workflowRecordTable.Redrive(failedWorkflow, redrivenAt)
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+
+	isOpen := OpenShardOf(workflow.EventId, 4)
+	expectedWorkflow := workflow
+	expectedWorkflow.IsOpen = &isOpen
+	expectedWorkflow.StartAt = redrivenAt
+	expectedWorkflow.AmountOfStarts = 0
+	expectedWorkflow.AmountOfRedrives = 1
+
+	assert.Equal(t, expectedWorkflow, actualWorkflow)
+}
+
+func Test_WorkflowRecordTable_should_cancel_the_workflow_if_it_is_still_open(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	// the lease of a claimed workflow is released along with the cancellation
+	_, err = workflowRecordTable.Claim(workflow, "runner", time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC), time.Hour)
+	assert.NoError(t, err)
+
+	cancelledAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Cancel(workflow.EventId, workflow.TargetQueueUrl, cancelledAt, "order withdrawn")
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+
+	expectedWorkflow := workflow
+	expectedWorkflow.IsOpen = nil
+	expectedWorkflow.CancelledAt = &cancelledAt
+	expectedWorkflow.CancellationReason = aws.String("order withdrawn")
+	expectedWorkflow.State = StatusCancelled
+
+	assert.Equal(t, expectedWorkflow, actualWorkflow)
+	assert.Equal(t, StatusCancelled, actualWorkflow.Status())
+
+	err = workflowRecordTable.Cancel(workflow.EventId, workflow.TargetQueueUrl, cancelledAt, "order withdrawn")
+	assert.Equal(t, ErrWorkflowHadBeenFinished, err)
+}
+
+func Test_WorkflowRecordTable_should_cancel_the_workflow_in_a_transaction_if_it_is_still_open(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	cancelledAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	err = database.
+		NewTransaction().
+		Include(workflowRecordTable.TransactionalCancel(workflow.EventId, workflow.TargetQueueUrl, cancelledAt, "order withdrawn")).
+		Execute(dynamodbClient)
+	assert.NoError(t, err)
+
+	err = database.
+		NewTransaction().
+		Include(workflowRecordTable.TransactionalClose(workflow.EventId, workflow.TargetQueueUrl, cancelledAt)).
+		Execute(dynamodbClient)
+	assert.ErrorIs(t, err, database.ErrConditionalCheckFailed)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, actualWorkflow.Status())
+	assert.Nil(t, actualWorkflow.FinishedAt)
+}
+
+func Test_WorkflowRecordTable_should_reschedule_the_recurring_workflow_on_close(t *testing.T) {
+	var err error
+
+	schedule, err := NewCronSchedule("0 9 * * *")
+	assert.NoError(t, err)
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 9, 0, 0, 0, time.UTC))
+	WithSchedule(schedule)(&workflow)
+	workflow.AmountOfStarts = 2
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	_, err = workflowRecordTable.Claim(workflow, "runner", time.Date(2023, time.October, 16, 9, 0, 0, 0, time.UTC), time.Hour)
+	assert.NoError(t, err)
+
+	firstFinishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 9, 15, 0, 0, time.UTC))
+	err = workflowRecordTable.Close(workflow.EventId, workflow.TargetQueueUrl, firstFinishedAt)
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+
+	expectedWorkflow := workflow
+	expectedWorkflow.StartAt = zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 9, 0, 0, 0, time.UTC))
+	expectedWorkflow.AmountOfStarts = 0
+	expectedWorkflow.Occurrence = 1
+	expectedWorkflow.LastFinishedAt = &firstFinishedAt
+	assert.Equal(t, expectedWorkflow, actualWorkflow)
+
+	err = database.
+		NewTransaction().
+		Include(workflowRecordTable.TransactionalReschedule(workflow, firstFinishedAt)).
+		Execute(dynamodbClient)
+	assert.ErrorIs(t, err, database.ErrConditionalCheckFailed)
+
+	secondFinishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 9, 5, 0, 0, time.UTC))
+	err = workflowRecordTable.Close(workflow.EventId, workflow.TargetQueueUrl, secondFinishedAt)
+	assert.NoError(t, err)
+
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, actualWorkflow.Occurrence)
+	assert.Equal(t, zulu.DateTimeFromTime(time.Date(2023, time.October, 18, 9, 0, 0, 0, time.UTC)), actualWorkflow.StartAt)
+	assert.Equal(t, StatusOpen, actualWorkflow.Status())
+}
+
+func Test_WorkflowRecordTable_should_reschedule_the_recurring_workflow_on_close_in_a_transaction(t *testing.T) {
+	var err error
+
+	schedule, err := NewIntervalSchedule(time.Hour)
+	assert.NoError(t, err)
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 9, 0, 0, 0, time.UTC))
+	WithSchedule(schedule)(&workflow)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 9, 15, 0, 0, time.UTC))
+	err = database.
+		NewTransaction().
+		Include(workflowRecordTable.TransactionalClose(workflow.EventId, workflow.TargetQueueUrl, finishedAt)).
+		Execute(dynamodbClient)
+	assert.NoError(t, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, actualWorkflow.Status())
+	assert.Equal(t, 1, actualWorkflow.Occurrence)
+	assert.Equal(t, zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 10, 15, 0, 0, time.UTC)), actualWorkflow.StartAt)
+}
+
+func Test_WorkflowRecordTable_should_not_finish_the_next_occurrence_on_a_duplicate_close(t *testing.T) {
+	var err error
+
+	schedule, err := NewIntervalSchedule(time.Hour)
+	assert.NoError(t, err)
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 9, 0, 0, 0, time.UTC))
+	WithSchedule(schedule)(&workflow)
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 9, 15, 0, 0, time.UTC))
+	err = workflowRecordTable.CloseOccurrence(workflow.EventId, workflow.TargetQueueUrl, 0, finishedAt)
+	assert.NoError(t, err)
+
+	duplicateFinishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 9, 16, 0, 0, time.UTC))
+	err = workflowRecordTable.CloseOccurrence(workflow.EventId, workflow.TargetQueueUrl, 0, duplicateFinishedAt)
+	assert.Equal(t, ErrWorkflowHadBeenFinished, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, actualWorkflow.Status())
+	assert.Equal(t, 1, actualWorkflow.Occurrence)
+	assert.Equal(t, &finishedAt, actualWorkflow.LastFinishedAt)
+	assert.Equal(t, zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 10, 15, 0, 0, time.UTC)), actualWorkflow.StartAt)
+}
+
+func Test_WorkflowRecordTable_should_let_only_one_runner_claim_the_workflow(t *testing.T) {
+	var err error
+
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	err = workflowRecordTable.Action(dynamodbClient).Persist(workflow)
+	assert.NoError(t, err)
+
+	now := time.Date(2023, time.October, 16, 13, 0, 0, 0, time.UTC)
+
+	claimedWorkflow, err := workflowRecordTable.Claim(workflow, "first runner", now, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "first runner", *claimedWorkflow.LeaseOwner)
+	assert.Equal(t, zulu.DateTimeFromTime(now.Add(time.Minute)), *claimedWorkflow.LeaseExpiresAt)
+
+	_, err = workflowRecordTable.Claim(workflow, "second runner", now.Add(time.Second*30), time.Minute)
+	assert.Equal(t, ErrWorkflowIsClaimed, err)
+
+	reclaimedWorkflow, err := workflowRecordTable.Claim(workflow, "second runner", now.Add(time.Minute*2), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "second runner", *reclaimedWorkflow.LeaseOwner)
+
+	nextStartAt := zulu.DateTimeFromTime(now.Add(time.Hour))
+	err = workflowRecordTable.Postpone(reclaimedWorkflow, nextStartAt)
+	assert.NoError(t, err)
+
+	_, err = workflowRecordTable.Claim(workflow, "third runner", now.Add(time.Minute*5), time.Minute)
+	assert.Equal(t, ErrWorkflowIsClaimed, err)
+
+	actualWorkflow := WorkflowRecord{
+		EventId:        workflow.EventId,
+		TargetQueueUrl: workflow.TargetQueueUrl,
+	}
+	err = workflowRecordTable.Action(dynamodbClient).Reconstitute(&actualWorkflow)
+	assert.NoError(t, err)
+	assert.Nil(t, actualWorkflow.LeaseOwner)
+	assert.Nil(t, actualWorkflow.LeaseExpiresAt)
+
+	actualWorkflow, err = workflowRecordTable.Claim(actualWorkflow, "third runner", now.Add(time.Minute*5), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "third runner", *actualWorkflow.LeaseOwner)
+}
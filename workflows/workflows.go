@@ -3,10 +3,16 @@ package workflows
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/gitlotto/common/database"
 	"github.com/gitlotto/common/zulu"
 )
@@ -15,16 +21,62 @@ func ErrFifoWorkflowQueueMismatch(queueUrl string) error {
 	return fmt.Errorf("fifo workflow should not delegate to a simple SQS queue %s", queueUrl)
 }
 
+func ErrAmbiguousEventId(eventId string) error {
+	return fmt.Errorf("event id %s does not consist of a table name, a partition key and an optional sort key", eventId)
+}
+
+func ErrStandardWorkflowQueueMismatch(queueUrl string) error {
+	return fmt.Errorf("standard workflow should not delegate to a fifo SQS queue %s", queueUrl)
+}
+
+func ErrUnknownTargetType(targetType TargetType) error {
+	return fmt.Errorf("unknown target type %s", targetType)
+}
+
+func ErrFifoWorkflowTargetMismatch(targetType TargetType) error {
+	return fmt.Errorf("fifo workflow should not delegate to the %s target which is never fifo", targetType)
+}
+
+func ErrEventIsNotJsonObject(targetType TargetType) error {
+	return fmt.Errorf("event of a workflow delegating to the %s target should be a JSON object", targetType)
+}
+
+// MaxMessageDelay is the longest delay SQS accepts for a single message.
+const MaxMessageDelay = time.Minute * 15
+
 type WorkflowRecord struct {
-	EventId             string         `dynamodbav:"event_id"`
-	TargetQueueUrl      string         `dynamodbav:"target_queue_url"`
-	CreatedAt           zulu.DateTime  `dynamodbav:"created_at"`
-	StartAt             zulu.DateTime  `dynamodbav:"start_at"`
-	AmountOfStarts      int            `dynamodbav:"amount_of_starts"`
-	IsOpen              *IsOpen        `dynamodbav:"is_open,omitempty"`
-	FinishedAt          *zulu.DateTime `dynamodbav:"finished_at,omitempty"`
-	Event               string         `dynamodbav:"event"`
-	EventMessageGroupId string         `dynamodbav:"event_message_group_id"`
+	EventId              string                `dynamodbav:"event_id"`
+	TargetQueueUrl       string                `dynamodbav:"target_queue_url"`
+	CreatedAt            zulu.DateTime         `dynamodbav:"created_at"`
+	StartAt              zulu.DateTime         `dynamodbav:"start_at"`
+	AmountOfStarts       int                   `dynamodbav:"amount_of_starts"`
+	IsOpen               *IsOpen               `dynamodbav:"is_open,omitempty"`
+	FinishedAt           *zulu.DateTime        `dynamodbav:"finished_at,omitempty"`
+	Event                string                `dynamodbav:"event"`
+	EventMessageGroupId  string                `dynamodbav:"event_message_group_id,omitempty"`
+	QueueKind            QueueKind             `dynamodbav:"queue_kind,omitempty"`
+	RetryPolicy          *RetryPolicy          `dynamodbav:"retry_policy,omitempty"`
+	IsFailed             *IsFailed             `dynamodbav:"is_failed,omitempty"`
+	FailedAt             *zulu.DateTime        `dynamodbav:"failed_at,omitempty"`
+	FailureReason        *string               `dynamodbav:"failure_reason,omitempty"`
+	AmountOfRedrives     int                   `dynamodbav:"amount_of_redrives,omitempty"`
+	CancelledAt          *zulu.DateTime        `dynamodbav:"cancelled_at,omitempty"`
+	CancellationReason   *string               `dynamodbav:"cancellation_reason,omitempty"`
+	Schedule             *Schedule             `dynamodbav:"schedule,omitempty"`
+	Occurrence           int                   `dynamodbav:"occurrence,omitempty"`
+	LastFinishedAt       *zulu.DateTime        `dynamodbav:"last_finished_at,omitempty"`
+	Attempts             []Attempt             `dynamodbav:"attempts,omitempty"`
+	LeaseOwner           *string               `dynamodbav:"lease_owner,omitempty"`
+	LeaseExpiresAt       *zulu.DateTime        `dynamodbav:"lease_expires_at,omitempty"`
+	Result               *string               `dynamodbav:"result,omitempty"`
+	ParentEventId        *string               `dynamodbav:"parent_event_id,omitempty"`
+	ParentTargetQueueUrl *string               `dynamodbav:"parent_target_queue_url,omitempty"`
+	SagaPosition         *SagaPosition         `dynamodbav:"saga_position,omitempty"`
+	Deduplication        DeduplicationStrategy `dynamodbav:"deduplication,omitempty"`
+	State                WorkflowStatus        `dynamodbav:"workflow_state,omitempty"`
+	TargetType           TargetType            `dynamodbav:"target_type,omitempty"`
+	OpenShards           int                   `dynamodbav:"open_shards,omitempty"`
+	WaitingFor           *string               `dynamodbav:"waiting_for,omitempty"`
 }
 
 func (record WorkflowRecord) ThePrimaryKey() database.PrimaryKey {
@@ -42,10 +94,84 @@ func (record WorkflowRecord) ThePrimaryKey() database.PrimaryKey {
 	}
 }
 
-func (record WorkflowRecord) EventMessageDeduplicationId() string {
-	deduplicationIdInBytes := sha256.Sum256([]byte(record.EventId))
-	deduplicationIdInString := hex.EncodeToString(deduplicationIdInBytes[:])
-	return deduplicationIdInString
+// RequiredAttributes makes stream images without the key of the workflow or its start time fail to decode.
+func (record WorkflowRecord) RequiredAttributes() []string {
+	return []string{"event_id", "target_queue_url", "created_at", "start_at", "event"}
+}
+
+// IsFifo is true for the workflows created before the queue kind had been stored as well.
+func (record WorkflowRecord) IsFifo() bool {
+	return record.QueueKind != StandardQueue
+}
+
+// Target is SQS for the workflows created before the target type had been stored.
+func (record WorkflowRecord) Target() TargetType {
+	if record.TargetType == "" {
+		return SqsTarget
+	}
+	return record.TargetType
+}
+
+// SupportsDelay is only true for standard SQS queues, the other targets deliver the event right away.
+func (record WorkflowRecord) SupportsDelay() bool {
+	return record.Target() == SqsTarget && !record.IsFifo()
+}
+
+// MessageAttributes are carried by the message of the workflow event whatever the target is,
+// so the receiver can tell which workflow and which occurrence of it has sent it.
+func (record WorkflowRecord) MessageAttributes() map[string]string {
+	return map[string]string{
+		"EventId":        record.EventId,
+		"TargetQueueUrl": record.TargetQueueUrl,
+		"Occurrence":     strconv.Itoa(record.Occurrence),
+	}
+}
+
+// EventMessageDeduplicationId hashes the source given by the deduplication strategy of the workflow.
+func (record WorkflowRecord) EventMessageDeduplicationId() (deduplicationId string, err error) {
+	source, err := record.deduplicationSource()
+	if err != nil {
+		return
+	}
+	deduplicationIdInBytes := sha256.Sum256([]byte(source))
+	deduplicationId = hex.EncodeToString(deduplicationIdInBytes[:])
+	return
+}
+
+// NextStartIn is the delay computed by the retry policy of the workflow or the default delay if it has none.
+func (record WorkflowRecord) NextStartIn(defaultDelay time.Duration) time.Duration {
+	if record.RetryPolicy == nil {
+		return defaultDelay
+	}
+	return record.RetryPolicy.NextDelay(record.AmountOfStarts)
+}
+
+// MarshalDynamoDBAttributeValue stores the status derived by Status as workflow_state,
+// so a persisted workflow never has a state disagreeing with its attributes.
+func (record WorkflowRecord) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) (err error) {
+	type workflowRecordAttributes WorkflowRecord
+	record.State = record.Status()
+	av.M, err = dynamodbattribute.MarshalMap(workflowRecordAttributes(record))
+	return
+}
+
+func (record WorkflowRecord) Status() WorkflowStatus {
+	switch {
+	case record.IsOpen != nil:
+		return StatusOpen
+	case record.IsFailed != nil:
+		return StatusFailed
+	case record.CancelledAt != nil:
+		return StatusCancelled
+	case record.WaitingFor != nil:
+		return StatusWaiting
+	default:
+		return StatusFinished
+	}
+}
+
+func (record WorkflowRecord) AttemptsExhausted() bool {
+	return record.RetryPolicy != nil && record.RetryPolicy.Exhausted(record.AmountOfStarts)
 }
 
 func NewFifoWorkflowRecord(
@@ -57,6 +183,7 @@ func NewFifoWorkflowRecord(
 	targetQueueUrl string,
 	event string,
 	eventGroupId string,
+	options ...WorkflowOption,
 ) (*WorkflowRecord, error) {
 	if targetQueueUrl == "" || !strings.HasSuffix(targetQueueUrl, ".fifo") {
 		return nil, ErrFifoWorkflowQueueMismatch(targetQueueUrl)
@@ -74,21 +201,190 @@ func NewFifoWorkflowRecord(
 		TargetQueueUrl:      targetQueueUrl,
 		Event:               event,
 		EventMessageGroupId: eventGroupId,
+		QueueKind:           FifoQueue,
+	}
+	for _, option := range options {
+		option(&workflowRecord)
+	}
+	workflowRecord.State = workflowRecord.Status()
+	err := workflowRecord.validate()
+	if err != nil {
+		return nil, err
+	}
+	return &workflowRecord, nil
+}
+
+func NewStandardWorkflowRecord(
+	tableName string,
+	partitionKey string,
+	sortKey *string,
+	createdAt zulu.DateTime,
+	startAt zulu.DateTime,
+	targetQueueUrl string,
+	event string,
+	options ...WorkflowOption,
+) (*WorkflowRecord, error) {
+	if targetQueueUrl == "" || strings.HasSuffix(targetQueueUrl, ".fifo") {
+		return nil, ErrStandardWorkflowQueueMismatch(targetQueueUrl)
+	}
+	isOpen := Open
+	eventId := NewEventId(tableName, partitionKey, sortKey)
+	workflowRecord := WorkflowRecord{
+		EventId:        eventId.String(),
+		CreatedAt:      createdAt,
+		StartAt:        startAt,
+		AmountOfStarts: 0,
+		IsOpen:         &isOpen,
+		TargetQueueUrl: targetQueueUrl,
+		Event:          event,
+		QueueKind:      StandardQueue,
+	}
+	for _, option := range options {
+		option(&workflowRecord)
+	}
+	workflowRecord.State = workflowRecord.Status()
+	err := workflowRecord.validate()
+	if err != nil {
+		return nil, err
 	}
 	return &workflowRecord, nil
 }
 
+type QueueKind string
+
+const (
+	FifoQueue     QueueKind = "FIFO"
+	StandardQueue QueueKind = "STANDARD"
+)
+
+// TargetType tells where the event of the workflow is published. TargetQueueUrl holds the queue url of SQS,
+// the topic arn of SNS or the bus name of EventBridge.
+type TargetType string
+
+const (
+	SqsTarget         TargetType = "SQS"
+	SnsTarget         TargetType = "SNS"
+	EventBridgeTarget TargetType = "EVENT_BRIDGE"
+)
+
+// validate rejects the workflows every publication of which would fail, since they would be retried forever.
+func (record WorkflowRecord) validate() (err error) {
+	err = record.validateTarget()
+	if err != nil || !record.IsFifo() {
+		return
+	}
+	_, err = record.deduplicationSource()
+	return
+}
+
+func (record WorkflowRecord) validateTarget() (err error) {
+	switch record.Target() {
+	case SqsTarget, SnsTarget:
+	case EventBridgeTarget:
+		if record.IsFifo() {
+			return ErrFifoWorkflowTargetMismatch(EventBridgeTarget)
+		}
+		var detail map[string]json.RawMessage
+		if json.Unmarshal([]byte(record.Event), &detail) != nil || detail == nil {
+			return ErrEventIsNotJsonObject(EventBridgeTarget)
+		}
+	default:
+		return ErrUnknownTargetType(record.Target())
+	}
+	return
+}
+
+type WorkflowOption func(workflowRecord *WorkflowRecord)
+
+func WithTargetType(targetType TargetType) WorkflowOption {
+	return func(workflowRecord *WorkflowRecord) {
+		workflowRecord.TargetType = targetType
+	}
+}
+
+// WithShards spreads open workflows over the given amount of is_open partitions of the open workflows index.
+// The amount has to match the Shards of the OpenWorkflowsIndex reading them. It is stored on the workflow,
+// so reopening the workflow puts it back to the same shard.
+func WithShards(shards int) WorkflowOption {
+	return func(workflowRecord *WorkflowRecord) {
+		if workflowRecord.IsOpen == nil {
+			return
+		}
+		workflowRecord.OpenShards = shards
+		isOpen := workflowRecord.OpenShard()
+		workflowRecord.IsOpen = &isOpen
+	}
+}
+
 type IsOpen string
 
 const (
 	Open IsOpen = "OPEN"
 )
 
+func OpenShard(shard int) IsOpen {
+	return IsOpen(string(Open) + "#" + strconv.Itoa(shard))
+}
+
+// OpenShard is the is_open partition of the workflow by the amount of shards stored on it.
+func (record WorkflowRecord) OpenShard() IsOpen {
+	return OpenShardOf(record.EventId, record.OpenShards)
+}
+
+// OpenShardOf picks the shard of the workflow by the hash of its EventId. Less than two shards mean no sharding.
+func OpenShardOf(eventId string, shards int) IsOpen {
+	if shards < 2 {
+		return Open
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(eventId))
+	return OpenShard(int(hash.Sum32() % uint32(shards)))
+}
+
+// WorkflowStatus is derived from the is_open, is_failed, cancelled_at and waiting_for attributes by Status.
+// It is stored as workflow_state as well, so the workflows can be looked up by it.
+type WorkflowStatus string
+
+// attributeValue is the workflow_state set by the update expressions along with the attributes the status is derived from.
+func (status WorkflowStatus) attributeValue() *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{
+		S: aws.String(string(status)),
+	}
+}
+
+const (
+	StatusOpen      WorkflowStatus = "OPEN"
+	StatusFinished  WorkflowStatus = "FINISHED"
+	StatusFailed    WorkflowStatus = "FAILED"
+	StatusCancelled WorkflowStatus = "CANCELLED"
+	// StatusWaiting is the status of a workflow that is opened once the workflow it is waiting for is finished.
+	StatusWaiting WorkflowStatus = "WAITING"
+)
+
+type IsFailed string
+
+const (
+	Failed IsFailed = "FAILED"
+)
+
 type EventId struct {
 	value string
 }
 
+// NewEventId joins the components with '#'. Backslashes and '#' inside the components are escaped with
+// a backslash, so ids of keys without them are the same as the ones stored before the escaping.
+// Workflows stored before the escaping for keys with them keep their LegacyEventId: WorkflowsOfKey
+// falls back to it, but a new workflow of such a key does not collide with the stored one.
 func NewEventId(tableName string, partitionKey string, sortKey *string) EventId {
+	idPrefix := escapeEventIdComponent(tableName) + "#" + escapeEventIdComponent(partitionKey)
+	if sortKey == nil {
+		return EventId{value: idPrefix}
+	}
+	return EventId{value: idPrefix + "#" + escapeEventIdComponent(*sortKey)}
+}
+
+// LegacyEventId is the id the workflows had been stored with before the escaping of NewEventId.
+func LegacyEventId(tableName string, partitionKey string, sortKey *string) EventId {
 	idPrefix := tableName + "#" + partitionKey
 	if sortKey == nil {
 		return EventId{value: idPrefix}
@@ -96,6 +392,43 @@ func NewEventId(tableName string, partitionKey string, sortKey *string) EventId
 	return EventId{value: idPrefix + "#" + *sortKey}
 }
 
+// ParseEventId splits the id back into the components of NewEventId. Ids stored before the escaping whose keys
+// contain '#' can not be told apart and are reported as ambiguous.
+func ParseEventId(value string) (tableName string, partitionKey string, sortKey *string, err error) {
+	components := []string{}
+	var component strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && (value[i+1] == '\\' || value[i+1] == '#'):
+			i++
+			component.WriteByte(value[i])
+		case value[i] == '#':
+			components = append(components, component.String())
+			component.Reset()
+		default:
+			component.WriteByte(value[i])
+		}
+	}
+	components = append(components, component.String())
+
+	switch len(components) {
+	case 2:
+	case 3:
+		sortKey = &components[2]
+	default:
+		err = ErrAmbiguousEventId(value)
+		return
+	}
+	tableName, partitionKey = components[0], components[1]
+	return
+}
+
+var eventIdEscaper = strings.NewReplacer("\\", "\\\\", "#", "\\#")
+
+func escapeEventIdComponent(component string) string {
+	return eventIdEscaper.Replace(component)
+}
+
 func (id EventId) String() string {
 	return id.value
 }
@@ -74,6 +74,12 @@ func Test_new_fifo_workflowRecord_should_be_stored_in_correct_form(t *testing.T)
 		"event_message_group_id": {
 			S: aws.String(eventGroupId),
 		},
+		"queue_kind": {
+			S: aws.String(string(FifoQueue)),
+		},
+		"workflow_state": {
+			S: aws.String(string(StatusOpen)),
+		},
 	}
 
 	assert.Equal(t, expectedItems, actualItems)
@@ -115,6 +121,7 @@ func Test_Closed_WorkflowRecord_should_be_stored_in_correct(t *testing.T) {
 	workflow.AmountOfStarts = amountOfStarts
 	finishedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
 	workflow.FinishedAt = &finishedAt
+	workflow.State = StatusFinished
 
 	eventId := fmt.Sprintf("%s#%s#%s", tableName, partitionKey, sortKey)
 
@@ -145,6 +152,12 @@ func Test_Closed_WorkflowRecord_should_be_stored_in_correct(t *testing.T) {
 		"event_message_group_id": {
 			S: aws.String(eventGroupId),
 		},
+		"queue_kind": {
+			S: aws.String(string(FifoQueue)),
+		},
+		"workflow_state": {
+			S: aws.String(string(StatusFinished)),
+		},
 	}
 
 	assert.Equal(t, expectedItems, actualItems)
@@ -163,3 +176,156 @@ func Test_Closed_WorkflowRecord_should_be_stored_in_correct(t *testing.T) {
 	expectedWorkflow := *workflow
 	assert.Equal(t, expectedWorkflow, actualWorkflow)
 }
+
+func Test_new_fifo_workflowRecord_should_be_assigned_to_a_shard_of_its_event_id(t *testing.T) {
+
+	tableName := uuid.New().String()
+	partitionKey := uuid.New().String()
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	startAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	targetQueueUrl := uuid.New().String() + ".fifo"
+	eventGroupId := uuid.New().String()
+
+	workflow, err := NewFifoWorkflowRecord(tableName, partitionKey, nil, createdAt, startAt, targetQueueUrl, "event", eventGroupId, WithShards(8))
+	assert.NoError(t, err)
+
+	expectedIsOpen := OpenShardOf(workflow.EventId, 8)
+	assert.Equal(t, &expectedIsOpen, workflow.IsOpen)
+	assert.Regexp(t, `^OPEN#[0-7]$`, string(*workflow.IsOpen))
+	assert.Equal(t, 8, workflow.OpenShards)
+
+	unshardedWorkflow, err := NewFifoWorkflowRecord(tableName, partitionKey, nil, createdAt, startAt, targetQueueUrl, "event", eventGroupId, WithShards(1))
+	assert.NoError(t, err)
+	assert.Equal(t, Open, *unshardedWorkflow.IsOpen)
+}
+
+func Test_new_standard_workflowRecord_should_not_be_created_if_queue_is_fifo(t *testing.T) {
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	startAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	targetQueueUrl := uuid.New().String() + ".fifo"
+
+	workflow, err := NewStandardWorkflowRecord(uuid.New().String(), uuid.New().String(), nil, createdAt, startAt, targetQueueUrl, "event")
+	assert.Nil(t, workflow)
+	assert.Equal(t, ErrStandardWorkflowQueueMismatch(targetQueueUrl), err)
+}
+
+func Test_new_standard_workflowRecord_should_be_sent_without_fifo_parameters(t *testing.T) {
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+	startAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	targetQueueUrl := uuid.New().String()
+
+	workflow, err := NewStandardWorkflowRecord(uuid.New().String(), uuid.New().String(), nil, createdAt, startAt, targetQueueUrl, "event")
+	assert.NoError(t, err)
+	assert.Equal(t, StandardQueue, workflow.QueueKind)
+	assert.False(t, workflow.IsFifo())
+	assert.True(t, workflow.SupportsDelay())
+	assert.Equal(t, map[string]string{"EventId": workflow.EventId, "TargetQueueUrl": targetQueueUrl, "Occurrence": "0"}, workflow.MessageAttributes())
+}
+
+func Test_new_fifo_workflowRecord_should_be_sent_with_fifo_parameters(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	assert.True(t, workflow.IsFifo())
+	assert.False(t, workflow.SupportsDelay())
+
+	workflow.QueueKind = ""
+	assert.True(t, workflow.IsFifo())
+}
+
+func Test_WorkflowRecord_status_should_tell_how_the_workflow_ended(t *testing.T) {
+	workflow := makeWorkflowRecord(time.Date(2023, time.October, 16, 12, 45, 14, 0, time.UTC))
+	assert.Equal(t, StatusOpen, workflow.Status())
+
+	endedAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 17, 12, 45, 14, 0, time.UTC))
+	workflow.IsOpen = nil
+
+	finishedWorkflow := workflow
+	finishedWorkflow.FinishedAt = &endedAt
+	assert.Equal(t, StatusFinished, finishedWorkflow.Status())
+
+	cancelledWorkflow := workflow
+	cancelledWorkflow.CancelledAt = &endedAt
+	assert.Equal(t, StatusCancelled, cancelledWorkflow.Status())
+
+	isFailed := Failed
+	failedWorkflow := workflow
+	failedWorkflow.IsFailed = &isFailed
+	failedWorkflow.FailedAt = &endedAt
+	assert.Equal(t, StatusFailed, failedWorkflow.Status())
+}
+
+func Test_EventId_should_be_parsed_back_into_its_components(t *testing.T) {
+	sortKey := `sort\key#1`
+	eventId := NewEventId("lottery#tickets", "partition#key", &sortKey)
+	assert.Equal(t, `lottery\#tickets#partition\#key#sort\\key\#1`, eventId.String())
+
+	tableName, partitionKey, actualSortKey, err := ParseEventId(eventId.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "lottery#tickets", tableName)
+	assert.Equal(t, "partition#key", partitionKey)
+	assert.Equal(t, &sortKey, actualSortKey)
+
+	tableName, partitionKey, actualSortKey, err = ParseEventId(NewEventId("table", "partition", nil).String())
+	assert.NoError(t, err)
+	assert.Equal(t, "table", tableName)
+	assert.Equal(t, "partition", partitionKey)
+	assert.Nil(t, actualSortKey)
+}
+
+func Test_EventId_should_stay_the_same_for_keys_without_special_characters(t *testing.T) {
+	sortKey := "2023-10-16T12:45:14Z"
+	assert.Equal(t, "table#partition#2023-10-16T12:45:14Z", NewEventId("table", "partition", &sortKey).String())
+	assert.Equal(t, "table#partition", NewEventId("table", "partition", nil).String())
+}
+
+func Test_EventId_should_keep_the_legacy_id_of_keys_with_special_characters(t *testing.T) {
+	sortKey := `sort\key`
+	assert.Equal(t, `table#partition#with#hash#sort\key`, LegacyEventId("table", "partition#with#hash", &sortKey).String())
+	assert.Equal(t, `table#partition\#with\#hash#sort\\key`, NewEventId("table", "partition#with#hash", &sortKey).String())
+	assert.Equal(t, NewEventId("table", "partition", nil), LegacyEventId("table", "partition", nil))
+}
+
+func Test_EventId_should_be_ambiguous_if_stored_before_escaping_with_a_hash_in_the_keys(t *testing.T) {
+	_, _, _, err := ParseEventId("table#partition#with#hash")
+	assert.Equal(t, ErrAmbiguousEventId("table#partition#with#hash"), err)
+
+	_, _, _, err = ParseEventId("table")
+	assert.Equal(t, ErrAmbiguousEventId("table"), err)
+}
+
+func Test_WorkflowRecord_target_should_be_sqs_unless_told_otherwise(t *testing.T) {
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+
+	standardWorkflow, err := NewStandardWorkflowRecord("table", "partition", nil, createdAt, createdAt, "queue", "event")
+	assert.NoError(t, err)
+	assert.Equal(t, SqsTarget, standardWorkflow.Target())
+	assert.True(t, standardWorkflow.SupportsDelay())
+
+	fifoWorkflow := makeWorkflowRecord(createdAt.ToTime())
+	assert.Equal(t, SqsTarget, fifoWorkflow.Target())
+	assert.False(t, fifoWorkflow.SupportsDelay())
+
+	topicWorkflow, err := NewStandardWorkflowRecord("table", "partition", nil, createdAt, createdAt, "arn:aws:sns:us-east-1:000000000000:topic", "event", WithTargetType(SnsTarget))
+	assert.NoError(t, err)
+	assert.Equal(t, SnsTarget, topicWorkflow.Target())
+	assert.False(t, topicWorkflow.SupportsDelay())
+}
+
+func Test_WorkflowRecord_should_not_be_created_if_its_target_can_not_take_it(t *testing.T) {
+	createdAt := zulu.DateTimeFromTime(time.Date(2023, time.October, 15, 12, 45, 14, 0, time.UTC))
+
+	_, err := NewStandardWorkflowRecord("table", "partition", nil, createdAt, createdAt, "lottery", "not json", WithTargetType(EventBridgeTarget))
+	assert.Equal(t, ErrEventIsNotJsonObject(EventBridgeTarget), err)
+
+	_, err = NewStandardWorkflowRecord("table", "partition", nil, createdAt, createdAt, "lottery", `["ticket"]`, WithTargetType(EventBridgeTarget))
+	assert.Equal(t, ErrEventIsNotJsonObject(EventBridgeTarget), err)
+
+	_, err = NewFifoWorkflowRecord("table", "partition", nil, createdAt, createdAt, "lottery.fifo", `{"ticket":1}`, "group", WithTargetType(EventBridgeTarget))
+	assert.Equal(t, ErrFifoWorkflowTargetMismatch(EventBridgeTarget), err)
+
+	_, err = NewStandardWorkflowRecord("table", "partition", nil, createdAt, createdAt, "queue", "event", WithTargetType("KINESIS"))
+	assert.Equal(t, ErrUnknownTargetType("KINESIS"), err)
+
+	workflow, err := NewStandardWorkflowRecord("table", "partition", nil, createdAt, createdAt, "lottery", `{"ticket":1}`, WithTargetType(EventBridgeTarget))
+	assert.NoError(t, err)
+	assert.Equal(t, EventBridgeTarget, workflow.Target())
+}